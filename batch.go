@@ -0,0 +1,429 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rotisserie/eris"
+)
+
+var ErrBatchMismatch = eris.New("batch response does not match requested ids")
+
+// BatchMismatchError lists the ids that broke strict batch correlation.
+// It matches ErrBatchMismatch with errors.Is.
+type BatchMismatchError struct {
+	Missing    []any
+	Unexpected []any
+	Duplicate  []any
+}
+
+func (e *BatchMismatchError) Error() string {
+	return fmt.Sprintf(
+		"batch response does not match requested ids: missing=%v, unexpected=%v, duplicate=%v",
+		e.Missing, e.Unexpected, e.Duplicate,
+	)
+}
+
+func (e *BatchMismatchError) Is(target error) bool {
+	return target == ErrBatchMismatch
+}
+
+// BatchError is returned by a batch Execute, along with all results, when
+// some entries failed. Errors is indexed like the batch, nil for the entries
+// that succeeded. errors.As and errors.Is reach the entries' *RPCError.
+type BatchError struct {
+	errs []*RPCError
+}
+
+func (e *BatchError) Errors() []*RPCError {
+	return e.errs
+}
+
+func (e *BatchError) Error() string {
+	var b strings.Builder
+
+	failed := 0
+	for i, err := range e.errs {
+		if err == nil {
+			continue
+		}
+
+		if failed == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+
+		failed++
+		fmt.Fprintf(&b, "entry %d: %s", i, err.Error())
+	}
+
+	return fmt.Sprintf("%d of %d batch entries failed", failed, len(e.errs)) + b.String()
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.errs))
+	for _, err := range e.errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// batchError reports the entries of results that failed, or nil.
+func batchError[Resp any](results []RPCResponse[Resp]) error {
+	var errs []*RPCError
+
+	for i, res := range results {
+		if res.Error == nil {
+			continue
+		}
+
+		if errs == nil {
+			errs = make([]*RPCError, len(results))
+		}
+
+		errs[i] = res.Error
+	}
+
+	if errs == nil {
+		return nil
+	}
+
+	return &BatchError{errs: errs}
+}
+
+type rpcBatch[Params any, Resp any] struct {
+	requests []*rpcRequest[Params, Resp]
+}
+
+type praparedRPCBatch[Resp any] struct {
+	preparedHTTP
+	versions []string
+	ids      []any
+
+	// chunks are set instead of a single request by WithMaxBatchSize
+	chunks      []*praparedRPCBatch[Resp]
+	concurrency int
+}
+
+// batchEntry keeps the result undecoded until the entry is known to be
+// error-free, mirroring the single request decode.
+type batchEntry struct {
+	JSONRPC string    `json:"jsonrpc"`
+	Result  rawResult `json:"result"`
+	Error   *RPCError `json:"error,omitempty"`
+	ID      any       `json:"id"`
+}
+
+// NewBatch groups requests sharing params and result types into a single
+// JSON-RPC batch call.
+func NewBatch[Params any, Resp any](requests ...*rpcRequest[Params, Resp]) *rpcBatch[Params, Resp] {
+	return &rpcBatch[Params, Resp]{requests: requests}
+}
+
+func (b *rpcBatch[Params, Resp]) Add(requests ...*rpcRequest[Params, Resp]) *rpcBatch[Params, Resp] {
+	b.requests = append(b.requests, requests...)
+
+	return b
+}
+
+func (b *rpcBatch[Params, Resp]) Prepare(url string, opts ...PrepareOpt) *praparedRPCBatch[Resp] {
+	if len(b.requests) == 0 {
+		return &praparedRPCBatch[Resp]{preparedHTTP: preparedHTTP{err: eris.New("empty batch")}}
+	}
+
+	if size, concurrency := batchChunking(url, opts); size > 0 && len(b.requests) > size {
+		return b.prepareChunks(url, opts, size, concurrency)
+	}
+
+	payload := make([]any, 0, len(b.requests))
+	versions := make([]string, 0, len(b.requests))
+	ids := make([]any, 0, len(b.requests))
+
+	for _, req := range b.requests {
+		payload = append(payload, req.payload())
+		versions = append(versions, req.JSONRPC)
+		ids = append(ids, req.ID)
+	}
+
+	return &praparedRPCBatch[Resp]{
+		preparedHTTP: prepareHTTP(url, payload, opts),
+		versions:     versions,
+		ids:          ids,
+	}
+}
+
+func (b *rpcBatch[Params, Resp]) prepareChunks(url string, opts []PrepareOpt, size, concurrency int) *praparedRPCBatch[Resp] {
+	prepared := &praparedRPCBatch[Resp]{concurrency: max(concurrency, 1)}
+
+	for requests := range slices.Chunk(b.requests, size) {
+		chunk := NewBatch(requests...).Prepare(url, opts...)
+		if chunk.err != nil {
+			return chunk
+		}
+
+		prepared.chunks = append(prepared.chunks, chunk)
+		prepared.ids = append(prepared.ids, chunk.ids...)
+	}
+
+	prepared.preparedHTTP = prepared.chunks[0].preparedHTTP
+
+	return prepared
+}
+
+// batchChunking resolves the WithMaxBatchSize and WithBatchConcurrency
+// options before the batch is encoded.
+func batchChunking(url string, opts []PrepareOpt) (size, concurrency int) {
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return 0, 0
+	}
+
+	cfg := newPrepareConfig(req, opts)
+
+	return cfg.maxBatchSize, cfg.batchConcurrency
+}
+
+// Execute sends the batch and returns one response per request, in request
+// order, matched by id. Per-entry failures are reported in the Error field
+// of the entry and, together, as a *BatchError returned along with all the
+// results. Any other error, e.g. in transport, aborts the whole batch and no
+// results are returned. Without WithStrictBatchCorrelation, an entry for
+// which the server sent no response is left with a nil ID and no result.
+func (b *praparedRPCBatch[Resp]) Execute(client *http.Client, opts ...ExecuteOpt) ([]RPCResponse[Resp], error) {
+	if b.err != nil {
+		return nil, eris.Wrap(b.err, "execute prepared batch")
+	}
+
+	release, err := b.acquire()
+	if err != nil {
+		return nil, eris.Wrapf(err, "execute batch of %d on %s", len(b.ids), b.endpoint())
+	}
+	defer release()
+
+	results, err := b.execute(client, opts)
+	if err != nil {
+		return nil, eris.Wrapf(err, "execute batch of %d on %s", len(b.ids), b.endpoint())
+	}
+
+	return results, batchError(results)
+}
+
+func (b *praparedRPCBatch[Resp]) execute(client *http.Client, opts []ExecuteOpt) ([]RPCResponse[Resp], error) {
+	if b.chunks != nil {
+		return b.executeChunks(client, opts)
+	}
+
+	req, err := b.beforeSend(b.internal, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.doRequest(req, client, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	raw, err := b.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.config.ndjson {
+		entries, err := decodeNDJSON(b.config.codec, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.correlate(entries)
+	}
+
+	// a server that rejects the whole batch answers with a single error object
+	if trimmed := strings.TrimLeft(raw, " \t\r\n"); strings.HasPrefix(trimmed, "{") {
+		var single rpcErrorProbe
+		if err := unmarshalString(b.config.codec, raw, &single); err != nil {
+			return nil, eris.Wrap(err, "decode batch response")
+		}
+
+		if single.Error != nil {
+			return nil, single.Error
+		}
+
+		return nil, eris.New("decode batch response: expected an array")
+	}
+
+	var entries []batchEntry
+	if err := unmarshalString(b.config.codec, raw, &entries); err != nil {
+		return nil, eris.Wrap(err, "decode batch response")
+	}
+
+	return b.correlate(entries)
+}
+
+// decodeNDJSON decodes one envelope per line, skipping blank lines.
+func decodeNDJSON(codec Codec, raw string) ([]batchEntry, error) {
+	var entries []batchEntry
+
+	for n, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var entry batchEntry
+		if err := unmarshalString(codec, line, &entry); err != nil {
+			return nil, eris.Wrapf(err, "decode batch response line %d", n+1)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// a server that rejects the whole batch answers with a single error object
+	if len(entries) == 1 && entries[0].ID == nil && entries[0].Error != nil {
+		return nil, entries[0].Error
+	}
+
+	return entries, nil
+}
+
+// executeChunks sends the chunks, at most b.concurrency at once, and joins
+// their results in request order. The first chunk to fail cancels the ones
+// in flight, no further chunks are sent and its error fails the batch.
+func (b *praparedRPCBatch[Resp]) executeChunks(client *http.Client, opts []ExecuteOpt) ([]RPCResponse[Resp], error) {
+	ctx, cancel := context.WithCancel(b.internal.Context())
+	defer cancel()
+
+	results := make([][]RPCResponse[Resp], len(b.chunks))
+	sem := make(chan struct{}, b.concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failed   error
+	)
+
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failed = err
+			cancel()
+		})
+	}
+
+	for i, chunk := range b.chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		// the chunk keeps its own body and options, only the context changes
+		sibling := *chunk
+		sibling.internal = chunk.internal.WithContext(ctx)
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			var err error
+
+			results[i], err = sibling.execute(client, opts)
+			if err != nil {
+				fail(eris.Wrapf(err, "chunk %d of %d", i+1, len(b.chunks)))
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+
+	// canceled by the caller before any chunk failed
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return slices.Concat(results...), nil
+}
+
+func (b *praparedRPCBatch[Resp]) correlate(entries []batchEntry) ([]RPCResponse[Resp], error) {
+	index := make(map[string]int, len(b.ids))
+	for i, id := range b.ids {
+		index[idKey(id)] = i
+	}
+
+	results := make([]RPCResponse[Resp], len(b.ids))
+	seen := make([]bool, len(b.ids))
+
+	var mismatch BatchMismatchError
+
+	for _, entry := range entries {
+		i, ok := index[idKey(entry.ID)]
+		if !ok {
+			mismatch.Unexpected = append(mismatch.Unexpected, entry.ID)
+			continue
+		}
+
+		if seen[i] {
+			mismatch.Duplicate = append(mismatch.Duplicate, entry.ID)
+			continue
+		}
+
+		seen[i] = true
+
+		if b.config.onVersionMismatch != nil && entry.JSONRPC != b.versions[i] {
+			b.config.onVersionMismatch(entry.JSONRPC)
+		}
+
+		if err := b.config.checkVersion(entry.ID, entry.JSONRPC); err != nil {
+			return nil, err
+		}
+
+		results[i] = RPCResponse[Resp]{
+			JSONRPC: entry.JSONRPC,
+			Error:   entry.Error,
+			ID:      entry.ID,
+		}
+
+		if entry.Error != nil && len(entry.Result) > 0 && string(entry.Result) != "null" {
+			return nil, &AmbiguousResponseError{ID: entry.ID, Err: entry.Error}
+		}
+
+		if entry.Error != nil || len(entry.Result) == 0 {
+			continue
+		}
+
+		if err := b.config.codec.Unmarshal(entry.Result, &results[i].Result); err != nil {
+			return nil, eris.Wrapf(err, "decode batch response result for id %v", entry.ID)
+		}
+	}
+
+	if !b.config.strictBatch {
+		return results, nil
+	}
+
+	for i, ok := range seen {
+		if !ok {
+			mismatch.Missing = append(mismatch.Missing, b.ids[i])
+		}
+	}
+
+	if len(mismatch.Missing) > 0 || len(mismatch.Unexpected) > 0 || len(mismatch.Duplicate) > 0 {
+		return nil, &mismatch
+	}
+
+	return results, nil
+}
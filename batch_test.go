@@ -0,0 +1,445 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded []map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestBatchExecuteCorrelatesByID(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded []struct {
+			Method string `json:"method"`
+			Params []int  `json:"params"`
+			ID     int    `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+		require.Len(t, decoded, 3)
+
+		// answer in reverse order, with an error for the second entry
+		fmt.Fprintf(w, `[
+			{"jsonrpc":"2.0","result":"hash-%d","id":%d},
+			{"jsonrpc":"2.0","error":{"code":-8,"message":"Block height out of range"},"id":%d},
+			{"jsonrpc":"2.0","result":"hash-%d","id":%d}
+		]`, decoded[2].Params[0], decoded[2].ID, decoded[1].ID, decoded[0].Params[0], decoded[0].ID)
+	}))
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 3 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	results, err := batch.Prepare(server.URL).Execute(server.Client())
+	require.Len(t, results, 3)
+
+	var batchErr *jsonrpc.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Errors(), 3)
+	require.Nil(t, batchErr.Errors()[0])
+	require.Equal(t, -8, batchErr.Errors()[1].Code)
+	require.Nil(t, batchErr.Errors()[2])
+	require.EqualError(t, err, "1 of 3 batch entries failed: entry 1: jsonrpc error: code=-8, message=Block height out of range")
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -8, rpcErr.Code)
+
+	require.Nil(t, results[0].Error)
+	require.Equal(t, "hash-100", results[0].Result)
+
+	require.NotNil(t, results[1].Error)
+	require.Equal(t, -8, results[1].Error.Code)
+	require.Empty(t, results[1].Result)
+
+	require.Nil(t, results[2].Error)
+	require.Equal(t, "hash-102", results[2].Result)
+}
+
+func TestBatchExecuteWholeBatchError(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](1)),
+	)
+
+	_, err := batch.Prepare(server.URL).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrInvalidRequest)
+}
+
+func TestBatchExecuteNDJSON(t *testing.T) {
+	t.Parallel()
+
+	// out of order, with a blank line, CRLF endings and a trailing newline
+	server := newBatchServer(t, "{\"jsonrpc\":\"2.0\",\"result\":20,\"id\":2}\r\n\n"+
+		"{\"jsonrpc\":\"2.0\",\"result\":10,\"id\":1}\n")
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](1)),
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](2)),
+	)
+
+	results, err := batch.Prepare(server.URL, jsonrpc.WithNDJSONBatchResponse()).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, 10, results[0].Result)
+	require.Equal(t, 20, results[1].Result)
+
+	// without the option the body is no JSON array
+	_, err = batch.Prepare(server.URL).Execute(server.Client())
+	require.Error(t, err)
+}
+
+func TestBatchExecuteNDJSONWholeBatchError(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`+"\n")
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](1)),
+	)
+
+	_, err := batch.Prepare(server.URL, jsonrpc.WithNDJSONBatchResponse()).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrInvalidRequest)
+}
+
+func TestBatchExecuteAmbiguousEntry(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, `[{"jsonrpc":"2.0","result":7,"error":{"code":-8,"message":"stale"},"id":1}]`)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](1)),
+	)
+
+	_, err := batch.Prepare(server.URL).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrAmbiguousResponse)
+
+	var ambiguous *jsonrpc.AmbiguousResponseError
+	require.ErrorAs(t, err, &ambiguous)
+	require.Equal(t, -8, ambiguous.Err.Code)
+}
+
+func TestBatchExecuteRequireVersion(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, `[{"result":"a","id":1},{"jsonrpc":"2.0","result":"b","id":2}]`)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("echo", []int{1}, jsonrpc.WithRPCid[[]int, string](1)),
+		jsonrpc.NewRequest("echo", []int{2}, jsonrpc.WithRPCid[[]int, string](2)),
+	)
+
+	// lenient by default
+	results, err := batch.Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "a", results[0].Result)
+
+	_, err = batch.Prepare(server.URL, jsonrpc.WithRequireVersion("2.0")).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrVersionMismatch)
+
+	var versionErr *jsonrpc.VersionError
+	require.ErrorAs(t, err, &versionErr)
+	require.Empty(t, versionErr.Got)
+}
+
+func TestBatchExecuteEmpty(t *testing.T) {
+	t.Parallel()
+
+	_, err := jsonrpc.NewBatch[[]int, int]().Prepare("http://unused.invalid").Execute(nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "empty batch")
+}
+
+func TestBatchStrictCorrelation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		body       string
+		missing    []any
+		unexpected []any
+		duplicate  []any
+	}{
+		{
+			name:    "missing",
+			body:    `[{"jsonrpc":"2.0","result":1,"id":1}]`,
+			missing: []any{2},
+		},
+		{
+			name:       "extra",
+			body:       `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":2},{"jsonrpc":"2.0","result":3,"id":3}]`,
+			unexpected: []any{float64(3)},
+		},
+		{
+			name:      "duplicate",
+			body:      `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":2},{"jsonrpc":"2.0","result":2,"id":2}]`,
+			duplicate: []any{float64(2)},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newBatchServer(t, tc.body)
+			defer server.Close()
+
+			batch := jsonrpc.NewBatch(
+				jsonrpc.NewRequest("n", []int{1}, jsonrpc.WithRPCid[[]int, int](1)),
+				jsonrpc.NewRequest("n", []int{2}, jsonrpc.WithRPCid[[]int, int](2)),
+			)
+
+			// lenient by default
+			results, err := batch.Prepare(server.URL).Execute(server.Client())
+			require.NoError(t, err)
+			require.Equal(t, 1, results[0].Result)
+
+			_, err = batch.Prepare(server.URL, jsonrpc.WithStrictBatchCorrelation()).Execute(server.Client())
+			require.ErrorIs(t, err, jsonrpc.ErrBatchMismatch)
+
+			var mismatch *jsonrpc.BatchMismatchError
+			require.ErrorAs(t, err, &mismatch)
+			require.Equal(t, tc.missing, mismatch.Missing)
+			require.Equal(t, tc.unexpected, mismatch.Unexpected)
+			require.Equal(t, tc.duplicate, mismatch.Duplicate)
+		})
+	}
+}
+
+func TestBatchExecuteCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	server := newBatchServer(t, `[]`)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	batch := jsonrpc.NewBatch(
+		jsonrpc.NewRequest("getblockcount", []int{}, jsonrpc.WithRPCid[[]int, int](1)),
+	)
+
+	_, err := batch.Prepare(server.URL, jsonrpc.WithContext(ctx)).Execute(server.Client())
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// newCappedBatchServer answers batches of up to size entries, with an error
+// for the block heights in failing, and rejects larger batches.
+func newCappedBatchServer(t *testing.T, size int, failing ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		var decoded []struct {
+			Params []int `json:"params"`
+			ID     int   `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+
+		if len(decoded) > size {
+			http.Error(w, "batch too large", http.StatusBadRequest)
+
+			return
+		}
+
+		entries := make([]string, 0, len(decoded))
+		for _, req := range decoded {
+			if slices.Contains(failing, req.Params[0]) {
+				entries = append(entries, fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-8,"message":"Block height out of range"},"id":%d}`, req.ID))
+			} else {
+				entries = append(entries, fmt.Sprintf(`{"jsonrpc":"2.0","result":"hash-%d","id":%d}`, req.Params[0], req.ID))
+			}
+		}
+
+		fmt.Fprint(w, "["+strings.Join(entries, ",")+"]")
+	}))
+
+	return server, &calls
+}
+
+func TestBatchExecuteSplitsByMaxBatchSize(t *testing.T) {
+	t.Parallel()
+
+	server, calls := newCappedBatchServer(t, 2, 103)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 5 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	results, err := batch.Prepare(server.URL, jsonrpc.WithMaxBatchSize(2)).Execute(server.Client())
+	require.Equal(t, int32(3), calls.Load())
+	require.Len(t, results, 5)
+
+	for i, res := range results {
+		if i == 3 {
+			continue
+		}
+
+		require.Equal(t, fmt.Sprintf("hash-%d", 100+i), res.Result)
+	}
+
+	// the failed entry keeps its index across chunk boundaries
+	var batchErr *jsonrpc.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Errors(), 5)
+	require.Equal(t, -8, batchErr.Errors()[3].Code)
+	require.Equal(t, -8, results[3].Error.Code)
+	require.Equal(t, float64(4), results[3].ID)
+}
+
+func TestBatchExecuteSendsChunksConcurrently(t *testing.T) {
+	t.Parallel()
+
+	server, calls := newCappedBatchServer(t, 3)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 10 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	results, err := batch.Prepare(
+		server.URL,
+		jsonrpc.WithMaxBatchSize(3),
+		jsonrpc.WithBatchConcurrency(4),
+	).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, int32(4), calls.Load())
+	require.Len(t, results, 10)
+
+	for i, res := range results {
+		require.Equal(t, fmt.Sprintf("hash-%d", 100+i), res.Result)
+	}
+}
+
+func TestBatchExecuteFailsWithChunk(t *testing.T) {
+	t.Parallel()
+
+	server, calls := newCappedBatchServer(t, 2)
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 6 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	// chunks of 3 exceed the server cap, so the first one fails the batch
+	results, err := batch.Prepare(server.URL, jsonrpc.WithMaxBatchSize(3)).Execute(server.Client())
+	require.ErrorContains(t, err, "chunk 1 of 2")
+	require.Nil(t, results)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestBatchExecuteCancelsSiblingChunks(t *testing.T) {
+	t.Parallel()
+
+	var canceled atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded []struct {
+			Params []int `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		if decoded[0].Params[0] == 100 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+
+			return
+		}
+
+		// the other chunks hang until the failed one cancels them
+		<-r.Context().Done()
+		canceled.Add(1)
+	}))
+	defer server.Close()
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 3 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	results, err := batch.Prepare(
+		server.URL,
+		jsonrpc.WithMaxBatchSize(1),
+		jsonrpc.WithBatchConcurrency(3),
+	).Execute(server.Client())
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusInternalServerError, httpErr.StatusCode)
+	require.ErrorContains(t, err, "chunk 1 of 3")
+	require.Nil(t, results)
+
+	require.Eventually(t, func() bool { return canceled.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBatchExecuteChunksRespectsContext(t *testing.T) {
+	t.Parallel()
+
+	arrived := make(chan struct{}, 2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	batch := jsonrpc.NewBatch[[]int, string]()
+	for i := range 4 {
+		batch.Add(jsonrpc.NewRequest("getblockhash", []int{100 + i}, jsonrpc.WithRPCid[[]int, string](i+1)))
+	}
+
+	go func() {
+		<-arrived
+		<-arrived
+		cancel()
+	}()
+
+	_, err := batch.Prepare(
+		server.URL,
+		jsonrpc.WithContext(ctx),
+		jsonrpc.WithMaxBatchSize(1),
+		jsonrpc.WithBatchConcurrency(2),
+	).Execute(server.Client())
+	require.ErrorIs(t, err, context.Canceled)
+}
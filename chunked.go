@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/rotisserie/eris"
+)
+
+// WithStreamedBody encodes the request while it is sent, using chunked
+// transfer encoding instead of a Content-Length, so huge params are never
+// held in memory as a whole. A streamed body cannot be replayed: every retry
+// attempt, and a hook capturing bodies, encodes the request again. With
+// WithRequestCompression the stream is always gzipped, as its size is not
+// known up front, and WithContentIdempotencyKey has no body to hash. Only
+// POST requests are streamed.
+func WithStreamedBody() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.streamBody = true
+	}
+}
+
+// setStreamedBody leaves the body to GetBody, which every attempt calls. The
+// transport closes the reader even when the request fails, which ends the
+// encoding goroutine.
+func setStreamedBody(req *http.Request, codec Codec, payload any, compress bool) {
+	req.ContentLength = -1
+
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+
+		go func() {
+			pw.CloseWithError(encodeStream(pw, codec, payload, compress))
+		}()
+
+		return pr, nil
+	}
+}
+
+func encodeStream(w io.Writer, codec Codec, payload any, compress bool) error {
+	if !compress {
+		return eris.Wrap(codec.NewEncoder(w).Encode(payload), "encode request data")
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := codec.NewEncoder(zw).Encode(payload); err != nil {
+		return eris.Wrap(err, "encode request data")
+	}
+
+	return eris.Wrap(zw.Close(), "gzip request body")
+}
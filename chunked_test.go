@@ -0,0 +1,121 @@
+package jsonrpc_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type streamedRequest struct {
+	contentLength int64
+	chunked       bool
+	body          string
+}
+
+// newStreamServer fails the first failures attempts with 503 and records how
+// every request body arrived, gunzipping it when needed.
+func newStreamServer(t *testing.T, failures int32) (*httptest.Server, chan streamedRequest) {
+	t.Helper()
+
+	var attempts atomic.Int32
+	seen := make(chan streamedRequest, 8)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body = zr
+		}
+
+		// a stream the client aborted, e.g. on an encoding error, ends early
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		seen <- streamedRequest{
+			contentLength: r.ContentLength,
+			chunked:       len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked",
+			body:          string(raw),
+		}
+
+		if attempts.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}`))
+	}))
+
+	return server, seen
+}
+
+func TestExecuteStreamedBody(t *testing.T) {
+	t.Parallel()
+
+	server, seen := newStreamServer(t, 1)
+	defer server.Close()
+
+	params := []string{strings.Repeat("ab", 64<<10)}
+	req := jsonrpc.NewRequest("sendrawtransaction", params, jsonrpc.WithRPCid[[]string, string](1))
+
+	res, err := req.Prepare(
+		server.URL,
+		jsonrpc.WithStreamedBody(),
+		jsonrpc.WithRetry(2, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond)),
+	).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	// the retry encodes the request again rather than replaying it
+	close(seen)
+	require.Len(t, seen, 2)
+
+	for got := range seen {
+		require.EqualValues(t, -1, got.contentLength)
+		require.True(t, got.chunked)
+		require.JSONEq(t, `{"jsonrpc":"2.0","method":"sendrawtransaction","params":["`+params[0]+`"],"id":1}`, got.body)
+	}
+}
+
+func TestExecuteStreamedCompressedBody(t *testing.T) {
+	t.Parallel()
+
+	server, seen := newStreamServer(t, 0)
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest("getblock", []string{"0000abc"}, jsonrpc.WithRPCid[[]string, string](1)).
+		Prepare(server.URL, jsonrpc.WithStreamedBody(), jsonrpc.WithRequestCompression(jsonrpc.DefaultCompressionThreshold)).
+		Execute(server.Client())
+	require.NoError(t, err)
+
+	got := <-seen
+	require.True(t, got.chunked)
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"getblock","params":["0000abc"],"id":1}`, got.body)
+}
+
+func TestExecuteStreamedBodyEncodeError(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newStreamServer(t, 0)
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest[[]any, string]("bad", []any{make(chan int)}).
+		Prepare(server.URL, jsonrpc.WithStreamedBody()).
+		Execute(server.Client())
+	require.ErrorContains(t, err, "encode request data")
+}
@@ -1,47 +1,193 @@
 package jsonrpc
 
 import (
-	"crypto/tls"
-	"net"
+	"context"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
+
+	"github.com/rotisserie/eris"
 )
 
+var defaultTransport = NewTransport(DefaultTransportConfig())
+
 var defaultHTTPClient = &http.Client{
-	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   5 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		ForceAttemptHTTP2: true,
-
-		// Large response tuning: allow many idle conns but cap concurrency
-		MaxIdleConns:        4_096,
-		MaxIdleConnsPerHost: 1_024,
-		MaxConnsPerHost:     512, // cap to bound memory while handling many large bodies
-
-		// Keep generous idle timeout for reuse; large responses take longer
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   5 * time.Second,
-		ExpectContinueTimeout: 250 * time.Millisecond,
-
-		// Enable compression to save bandwidth for multi-MB JSON; CPU tradeoff
-		DisableCompression: false,
-
-		// Increase per-connection IO buffers to reduce syscalls for large bodies
-		// Defaults are 4KB; bump to 64KB (common page multiple).
-		ReadBufferSize:  64 << 10,
-		WriteBufferSize: 64 << 10,
-
-		// TLS session cache to lower handshake CPU when many connections exist
-		TLSClientConfig: &tls.Config{
-			MinVersion:         tls.VersionTLS12,
-			ClientSessionCache: tls.NewLRUClientSessionCache(4096),
-		},
-
-		// HTTP/2: raise concurrent streams per connection for multiplexing large responses
-		// (Go picks defaults; env GODEBUG may tune; leaving default to avoid incompat issues)
-	},
-	Timeout: 0,
+	Transport: defaultTransport,
+	Timeout:   0,
+}
+
+// Client binds one or more endpoints, an HTTP client and default per-call
+// options so they are configured once and shared by many calls. It is safe
+// for concurrent use.
+type Client struct {
+	endpoints  []string
+	selector   EndpointSelector
+	httpClient *http.Client
+	opts       []PrepareOpt
+
+	methodMu   sync.RWMutex
+	methodOpts map[string][]PrepareOpt
+}
+
+type ClientOption func(*Client)
+
+func WithHTTPClient(cli *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = cli
+	}
+}
+
+func WithDefaultHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.opts = append(c.opts, WithHeader(key, value))
+	}
+}
+
+// WithDefaultContentType sends contentType instead of application/json on
+// every call, e.g. application/json-rpc for servers that require it. A
+// per-call WithContentType wins.
+func WithDefaultContentType(contentType string) ClientOption {
+	return func(c *Client) {
+		c.opts = append(c.opts, WithContentType(contentType))
+	}
+}
+
+// WithDefaultOptions adds prepare options applied to every call made through
+// the client, before the per-call ones.
+func WithDefaultOptions(opts ...PrepareOpt) ClientOption {
+	return func(c *Client) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// WithDefaultTimeout bounds calls whose context has no deadline, so callers
+// that pass context.Background() cannot hang forever. A deadline on the
+// context or a per-call WithTimeout wins.
+func WithDefaultTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.opts = append(c.opts, func(cfg *prepareConfig) {
+			cfg.defaultTimeout = d
+		})
+	}
+}
+
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		endpoints:  []string{url},
+		selector:   &RoundRobin{},
+		httpClient: defaultHTTPClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Clone returns a copy of the client with opts applied on top of its
+// configuration, e.g. a tenant's extra default header, leaving c as is. The
+// copy shares c's HTTP client, and so its connection pool, unless opts
+// replace or adjust the transport, and its endpoint selector.
+func (c *Client) Clone(opts ...ClientOption) *Client {
+	c.methodMu.RLock()
+	methodOpts := make(map[string][]PrepareOpt, len(c.methodOpts))
+	for method, mopts := range c.methodOpts {
+		methodOpts[method] = slices.Clone(mopts)
+	}
+	c.methodMu.RUnlock()
+
+	clone := &Client{
+		endpoints:  slices.Clone(c.endpoints),
+		selector:   c.selector,
+		httpClient: c.httpClient,
+		opts:       slices.Clone(c.opts),
+		methodOpts: methodOpts,
+	}
+
+	for _, opt := range opts {
+		opt(clone)
+	}
+
+	return clone
+}
+
+// CloseIdleConnections closes the idle keep-alive connections of the
+// client's transport, e.g. after rotating away from a provider, without
+// affecting calls in flight. A client on the shared default transport
+// closes the idle connections of every client sharing it. It is safe to
+// call concurrently and the client remains usable afterwards.
+func (c *Client) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// SetMethodDefaults replaces the options applied to every call of method
+// made through the client, e.g. a longer WithTimeout for heavy methods.
+// Options apply in order: package defaults, client defaults, method
+// defaults, then per-call options, so a per-call option wins. Calling it
+// without options clears the method's defaults.
+func (c *Client) SetMethodDefaults(method string, opts ...PrepareOpt) {
+	c.methodMu.Lock()
+	defer c.methodMu.Unlock()
+
+	if len(opts) == 0 {
+		delete(c.methodOpts, method)
+
+		return
+	}
+
+	if c.methodOpts == nil {
+		c.methodOpts = make(map[string][]PrepareOpt)
+	}
+
+	c.methodOpts[method] = append([]PrepareOpt(nil), opts...)
+}
+
+// Call invokes method and decodes the result into result, which must be a
+// pointer. Use (*rpcRequest).Send for a fully typed call.
+func (c *Client) Call(ctx context.Context, method string, params any, result any, opts ...PrepareOpt) error {
+	req := NewRequest[any, any](method, params)
+	opts = c.callOpts(ctx, method, opts)
+
+	return c.failover(ctx, func(url string) error {
+		prepared := req.Prepare(url, opts...)
+		if prepared.err != nil {
+			return eris.Wrap(prepared.err, "execute prepared request")
+		}
+
+		out := RPCResponse[any]{Result: result}
+
+		return prepared.execute(c.httpClient, nil, &out)
+	})
+}
+
+func (c *Client) callOpts(ctx context.Context, method string, opts []PrepareOpt) []PrepareOpt {
+	c.methodMu.RLock()
+	methodOpts := c.methodOpts[method]
+	c.methodMu.RUnlock()
+
+	all := make([]PrepareOpt, 0, len(c.opts)+len(methodOpts)+len(opts)+1)
+	all = append(all, WithContext(ctx))
+	all = append(all, c.opts...)
+	all = append(all, methodOpts...)
+
+	return append(all, opts...)
+}
+
+// Send prepares the request against the client's endpoints and executes it
+// with the client's configuration.
+func (r *rpcRequest[Params, Resp]) Send(ctx context.Context, c *Client, opts ...PrepareOpt) (*Resp, error) {
+	opts = c.callOpts(ctx, r.Method, opts)
+
+	var res *Resp
+
+	err := c.failover(ctx, func(url string) error {
+		var err error
+		res, err = r.Prepare(url, opts...).Execute(c.httpClient)
+
+		return err
+	})
+
+	return res, err
 }
@@ -0,0 +1,414 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type clientCall struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     any             `json:"id"`
+	Header http.Header     `json:"-"`
+}
+
+func newClientServer(t *testing.T, calls chan<- clientCall, result string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call clientCall
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		call.Header = r.Header.Clone()
+		calls <- call
+
+		idJSON, err := json.Marshal(call.ID)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%s,"id":%s}`, result, idJSON)
+	}))
+}
+
+func TestClientCallDecodesIntoResult(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `{"height":883189,"hash":"0000abc"}`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithDefaultHeader("Authorization", "Bearer shared"),
+	)
+
+	var block struct {
+		Height int    `json:"height"`
+		Hash   string `json:"hash"`
+	}
+
+	err := client.Call(context.Background(), "getblockheader", []any{"0000abc", true}, &block)
+	require.NoError(t, err)
+	require.Equal(t, 883189, block.Height)
+	require.Equal(t, "0000abc", block.Hash)
+
+	call := <-calls
+	require.Equal(t, "getblockheader", call.Method)
+	require.JSONEq(t, `["0000abc",true]`, string(call.Params))
+	require.Equal(t, "Bearer shared", call.Header.Get("Authorization"))
+}
+
+func TestClientSendTypedWithOverride(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `42`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithDefaultHeader("X-Tenant", "default"),
+	)
+
+	result, err := jsonrpc.NewRequest[[]int, int]("getblockcount", nil).
+		Send(context.Background(), client, jsonrpc.WithHeader("X-Tenant", "override"))
+	require.NoError(t, err)
+	require.Equal(t, 42, *result)
+
+	call := <-calls
+	require.Equal(t, "override", call.Header.Get("X-Tenant"))
+}
+
+func TestClientDefaultContentType(t *testing.T) {
+	t.Parallel()
+
+	// a strict server that only speaks application/json-rpc
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json-rpc" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json-rpc; charset=utf-8")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":42,"id":1}`)
+	}))
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithDefaultContentType("application/json-rpc"),
+		jsonrpc.WithDefaultOptions(jsonrpc.WithRequireJSONContentType()),
+	)
+
+	var height int
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &height))
+	require.Equal(t, 42, height)
+
+	err := client.Call(context.Background(), "getblockcount", nil, &height, jsonrpc.WithContentType("application/json"))
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusUnsupportedMediaType, httpErr.StatusCode)
+}
+
+func TestClientCallRPCError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`)
+	}))
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()))
+
+	var out string
+	err := client.Call(context.Background(), "nope", nil, &out)
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+}
+
+func TestClientCallCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `1`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out int
+	err := client.Call(ctx, "getblockcount", nil, &out)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClientDefaultTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithDefaultTimeout(30*time.Millisecond),
+	)
+
+	var out int
+
+	start := time.Now()
+	err := client.Call(context.Background(), "slow", nil, &out)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// a deadline on the caller's context wins over the default
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	err = client.Call(ctx, "slow", nil, &out)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestClientCloseIdleConnections(t *testing.T) {
+	t.Parallel()
+
+	closed := make(chan struct{}, 1)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":1,"id":null}`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTransportConfig(jsonrpc.DefaultTransportConfig()))
+
+	var out int
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &out))
+
+	client.CloseIdleConnections()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+
+	// the client keeps working on a fresh connection
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &out))
+}
+
+func newStatusServer(status int, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+}
+
+func TestClientFailsOverEndpoints(t *testing.T) {
+	t.Parallel()
+
+	var unavailableHits, healthyHits atomic.Int32
+
+	unavailable := newStatusServer(http.StatusServiceUnavailable, &unavailableHits)
+	defer unavailable.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	healthy := newStatusServer(http.StatusOK, &healthyHits)
+	defer healthy.Close()
+
+	client := jsonrpc.NewClient("",
+		jsonrpc.WithEndpoints([]string{unavailable.URL, down.URL, healthy.URL}),
+		jsonrpc.WithEndpointSelector(orderedSelector{}),
+		jsonrpc.WithDefaultOptions(jsonrpc.WithRetry(2, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))),
+	)
+
+	res, err := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{}).
+		Send(context.Background(), client)
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	// retries stay on an endpoint before the call moves to the next one
+	require.EqualValues(t, 2, unavailableHits.Load())
+	require.EqualValues(t, 1, healthyHits.Load())
+}
+
+func TestClientFailoverAggregatesErrors(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+
+	first := newStatusServer(http.StatusBadGateway, &hits)
+	defer first.Close()
+
+	second := newStatusServer(http.StatusInternalServerError, &hits)
+	defer second.Close()
+
+	client := jsonrpc.NewClient("", jsonrpc.WithEndpoints([]string{first.URL, second.URL}))
+
+	var res string
+	err := client.Call(context.Background(), "getblockcount", nil, &res)
+
+	var endpointsErr *jsonrpc.EndpointsError
+	require.ErrorAs(t, err, &endpointsErr)
+	require.Len(t, endpointsErr.Errors, 2)
+	require.ElementsMatch(t, []string{first.URL, second.URL},
+		[]string{endpointsErr.Errors[0].URL, endpointsErr.Errors[1].URL})
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.EqualValues(t, 2, hits.Load())
+}
+
+func TestClientFailoverStopsOnClientErrors(t *testing.T) {
+	t.Parallel()
+
+	var badHits, healthyHits atomic.Int32
+
+	bad := newStatusServer(http.StatusBadRequest, &badHits)
+	defer bad.Close()
+
+	healthy := newStatusServer(http.StatusOK, &healthyHits)
+	defer healthy.Close()
+
+	client := jsonrpc.NewClient("",
+		jsonrpc.WithEndpoints([]string{bad.URL, healthy.URL}),
+		jsonrpc.WithEndpointSelector(orderedSelector{}),
+	)
+
+	var res string
+	err := client.Call(context.Background(), "getblockcount", nil, &res)
+	require.Contains(t, err.Error(), "http status 400")
+	require.EqualValues(t, 0, healthyHits.Load())
+}
+
+func TestRoundRobinRotatesStart(t *testing.T) {
+	t.Parallel()
+
+	var rr jsonrpc.RoundRobin
+	endpoints := []string{"a", "b", "c"}
+
+	require.Equal(t, []string{"a", "b", "c"}, rr.Order(endpoints))
+	require.Equal(t, []string{"b", "c", "a"}, rr.Order(endpoints))
+	require.Equal(t, []string{"c", "a", "b"}, rr.Order(endpoints))
+	require.Equal(t, []string{"a", "b", "c"}, rr.Order(endpoints))
+}
+
+type orderedSelector struct{}
+
+func (orderedSelector) Order(endpoints []string) []string {
+	return endpoints
+}
+
+func TestClientMethodDefaults(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithDefaultHeader("X-Tier", "client"))
+	client.SetMethodDefaults("getblock", jsonrpc.WithHeader("X-Tier", "method"))
+
+	var res string
+
+	require.NoError(t, client.Call(context.Background(), "getblock", nil, &res))
+	require.Equal(t, "method", (<-calls).Header.Get("X-Tier"))
+
+	// per-call options override the method defaults
+	require.NoError(t, client.Call(context.Background(), "getblock", nil, &res, jsonrpc.WithHeader("X-Tier", "call")))
+	require.Equal(t, "call", (<-calls).Header.Get("X-Tier"))
+
+	// other methods only get the client defaults
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "client", (<-calls).Header.Get("X-Tier"))
+
+	_, err := jsonrpc.NewRequest[any, string]("getblock", nil).Send(context.Background(), client)
+	require.NoError(t, err)
+	require.Equal(t, "method", (<-calls).Header.Get("X-Tier"))
+
+	client.SetMethodDefaults("getblock")
+
+	require.NoError(t, client.Call(context.Background(), "getblock", nil, &res))
+	require.Equal(t, "client", (<-calls).Header.Get("X-Tier"))
+}
+
+func TestClientClone(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	var dials atomic.Int32
+
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			dials.Add(1)
+			return nil
+		},
+	}
+
+	base := jsonrpc.NewClient(server.URL,
+		jsonrpc.WithTransport(&http.Transport{}),
+		jsonrpc.WithDialer(dialer),
+		jsonrpc.WithDefaultHeader("Authorization", "Bearer shared"),
+	)
+	base.SetMethodDefaults("getblock", jsonrpc.WithHeader("X-Tier", "method"))
+
+	tenant := base.Clone(jsonrpc.WithDefaultHeader("X-Tenant", "acme"))
+	tenant.SetMethodDefaults("getblock")
+
+	var res string
+
+	require.NoError(t, tenant.Call(context.Background(), "getblock", nil, &res))
+	call := <-calls
+	require.Equal(t, "acme", call.Header.Get("X-Tenant"))
+	require.Equal(t, "Bearer shared", call.Header.Get("Authorization"))
+	require.Empty(t, call.Header.Get("X-Tier"))
+
+	// the original keeps its configuration
+	require.NoError(t, base.Call(context.Background(), "getblock", nil, &res))
+	call = <-calls
+	require.Empty(t, call.Header.Get("X-Tenant"))
+	require.Equal(t, "method", call.Header.Get("X-Tier"))
+
+	// and its connection pool is shared with the clone
+	require.Equal(t, int32(1), dials.Load())
+
+	// unless the clone adjusts its transport
+	require.NoError(t, base.Clone(jsonrpc.WithDisableKeepAlives()).Call(context.Background(), "getblock", nil, &res))
+	<-calls
+	require.Equal(t, int32(2), dials.Load())
+}
@@ -0,0 +1,84 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/rotisserie/eris"
+)
+
+type Encoder interface {
+	Encode(v any) error
+}
+
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec is the JSON implementation used to encode requests and decode
+// responses. Switching codecs does not change the wire format.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// stringUnmarshaler is implemented by codecs able to decode straight from a
+// string, which spares the copy of the response body on every decode pass.
+type stringUnmarshaler interface {
+	UnmarshalFromString(data string, v any) error
+}
+
+// strictUnmarshaler is implemented by codecs able to reject object members
+// the target type has no field for, as used by WithStrictDecode.
+type strictUnmarshaler interface {
+	UnmarshalStrict(data []byte, v any) error
+}
+
+var StdCodec Codec = stdCodec{}
+
+var defaultCodec = SonicCodec
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdCodec) UnmarshalStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+func (stdCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (stdCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func unmarshalString(codec Codec, data string, v any) error {
+	if su, ok := codec.(stringUnmarshaler); ok {
+		return su.UnmarshalFromString(data, v)
+	}
+
+	return codec.Unmarshal([]byte(data), v)
+}
+
+func unmarshalStrict(codec Codec, data []byte, v any) error {
+	su, ok := codec.(strictUnmarshaler)
+	if !ok {
+		return eris.New("codec does not support strict decoding")
+	}
+
+	return su.UnmarshalStrict(data, v)
+}
@@ -0,0 +1,44 @@
+//go:build amd64 || arm64
+
+package jsonrpc
+
+import (
+	"io"
+
+	"github.com/bytedance/sonic"
+)
+
+// SonicCodec is the default codec. On architectures other than amd64 and
+// arm64 it is StdCodec, as sonic does not build there.
+var SonicCodec Codec = sonicCodec{}
+
+var sonicStrict = sonic.Config{DisallowUnknownFields: true}.Froze()
+
+// rawResult references the response body instead of copying it.
+type rawResult = sonic.NoCopyRawMessage
+
+type sonicCodec struct{}
+
+func (sonicCodec) Marshal(v any) ([]byte, error) {
+	return sonic.ConfigDefault.Marshal(v)
+}
+
+func (sonicCodec) Unmarshal(data []byte, v any) error {
+	return sonic.ConfigDefault.Unmarshal(data, v)
+}
+
+func (sonicCodec) UnmarshalFromString(data string, v any) error {
+	return sonic.ConfigDefault.UnmarshalFromString(data, v)
+}
+
+func (sonicCodec) UnmarshalStrict(data []byte, v any) error {
+	return sonicStrict.Unmarshal(data, v)
+}
+
+func (sonicCodec) NewEncoder(w io.Writer) Encoder {
+	return sonic.ConfigDefault.NewEncoder(w)
+}
+
+func (sonicCodec) NewDecoder(r io.Reader) Decoder {
+	return sonic.ConfigDefault.NewDecoder(r)
+}
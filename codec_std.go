@@ -0,0 +1,11 @@
+//go:build !amd64 && !arm64
+
+package jsonrpc
+
+import "encoding/json"
+
+// SonicCodec falls back to encoding/json on architectures sonic does not
+// support, e.g. 386 or wasm, so the package builds everywhere.
+var SonicCodec = StdCodec
+
+type rawResult = json.RawMessage
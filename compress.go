@@ -0,0 +1,78 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+// DefaultCompressionThreshold is a size below which gzip rarely pays off.
+const DefaultCompressionThreshold = 1 << 10
+
+// WithRequestCompression gzips request bodies of at least minBytes and sets
+// Content-Encoding accordingly; smaller bodies are sent as is. The server
+// must accept gzip-encoded requests.
+func WithRequestCompression(minBytes int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.compress = true
+		c.compressMin = minBytes
+	}
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buff bytes.Buffer
+
+	zw := gzip.NewWriter(&buff)
+	if _, err := zw.Write(data); err != nil {
+		return nil, eris.Wrap(err, "gzip request body")
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, eris.Wrap(err, "gzip request body")
+	}
+
+	return buff.Bytes(), nil
+}
+
+// decodeContent undoes a Content-Encoding the transport left in place, which
+// happens when Accept-Encoding was set by hand or a custom transport is used.
+// It reports whether the body was wrapped.
+func decodeContent(resp *http.Response) (io.Reader, bool, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return resp.Body, false, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, false, eris.Wrap(err, "open gzip response")
+		}
+
+		return zr, true, nil
+	case "deflate":
+		return newDeflateReader(resp.Body), true, nil
+	default:
+		return nil, false, eris.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// newDeflateReader accepts both zlib-wrapped deflate, which is what the HTTP
+// spec means, and the raw deflate stream some servers send instead.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+
+	return flate.NewReader(br)
+}
@@ -0,0 +1,172 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/LiquidCats/jsonrpc/v2/tests/types"
+	"github.com/stretchr/testify/require"
+)
+
+type compressedRequest struct {
+	encoding      string
+	contentLength int64
+	wireBytes     int
+	body          string
+}
+
+func newDecompressingServer(t *testing.T, seen chan<- compressedRequest) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		body := raw
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(raw))
+			require.NoError(t, err)
+			body, err = io.ReadAll(zr)
+			require.NoError(t, err)
+		}
+
+		seen <- compressedRequest{
+			encoding:      r.Header.Get("Content-Encoding"),
+			contentLength: r.ContentLength,
+			wireBytes:     len(raw),
+			body:          string(body),
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+}
+
+func TestPrepareWithRequestCompression(t *testing.T) {
+	t.Parallel()
+
+	seen := make(chan compressedRequest, 1)
+	server := newDecompressingServer(t, seen)
+	defer server.Close()
+
+	txids := make([]string, 200)
+	for i := range txids {
+		txids[i] = fmt.Sprintf("%064x", i)
+	}
+
+	req := jsonrpc.NewRequest[[]string, string]("getrawtransaction", txids, jsonrpc.WithRPCid[[]string, string](1))
+
+	res, err := req.Prepare(server.URL, jsonrpc.WithRequestCompression(jsonrpc.DefaultCompressionThreshold)).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	got := <-seen
+	require.Equal(t, "gzip", got.encoding)
+	require.EqualValues(t, got.wireBytes, got.contentLength)
+	require.Less(t, got.wireBytes, len(got.body))
+
+	params := `["` + strings.Join(txids, `","`) + `"]`
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"getrawtransaction","params":`+params+`,"id":1}`, got.body)
+}
+
+func TestPrepareWithRequestCompressionSkipsSmallBodies(t *testing.T) {
+	t.Parallel()
+
+	seen := make(chan compressedRequest, 1)
+	server := newDecompressingServer(t, seen)
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{1}).
+		Prepare(server.URL, jsonrpc.WithRequestCompression(jsonrpc.DefaultCompressionThreshold)).
+		Execute(server.Client())
+	require.NoError(t, err)
+
+	got := <-seen
+	require.Empty(t, got.encoding)
+	require.EqualValues(t, len(got.body), got.contentLength)
+}
+
+func TestExecuteDecodesCompressedResponses(t *testing.T) {
+	t.Parallel()
+
+	fixture, err := os.ReadFile("tests/fixtures/btc-block-without-txs.json")
+	require.NoError(t, err)
+
+	encoded := map[string][]byte{}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err = gw.Write(fixture)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	encoded["gzip"] = gz.Bytes()
+
+	var zl bytes.Buffer
+	zw := zlib.NewWriter(&zl)
+	_, err = zw.Write(fixture)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	encoded["deflate"] = zl.Bytes()
+
+	var fl bytes.Buffer
+	fw, err := flate.NewWriter(&fl, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(fixture)
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+	encoded["raw-deflate"] = fl.Bytes()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		encoding := name
+		if name == "raw-deflate" {
+			encoding = "deflate"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		_, _ = w.Write(encoded[name])
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[[]any, types.Block]("getblock", []any{"00000000000000000001246cadf2834cf70fe92404e37c14e071f4b7da61993d", false})
+
+	for name := range encoded {
+		// a hand-set Accept-Encoding stops the transport from decompressing
+		block, err := req.Prepare(server.URL+"/"+name, jsonrpc.WithHeader("Accept-Encoding", "gzip, deflate")).
+			Execute(server.Client())
+		require.NoError(t, err, name)
+		require.Equal(t, 883189, block.Height, name)
+	}
+}
+
+func TestExecuteCompressedResponseRespectsLimit(t *testing.T) {
+	t.Parallel()
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte(`{"jsonrpc":"2.0","result":"` + strings.Repeat("a", 1<<20) + `","id":1}`))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gz.Bytes())
+	}))
+	defer server.Close()
+
+	_, err = jsonrpc.NewRequest[struct{}, string]("bomb", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithHeader("Accept-Encoding", "gzip"), jsonrpc.WithMaxResponseBytes(64<<10)).
+		Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrResponseTooLarge)
+}
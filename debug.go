@@ -0,0 +1,82 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// debugBodyLimit caps the bytes of each body written by WithDebug.
+const debugBodyLimit = 64 << 10
+
+// debugRedacted are the headers WithDebug never writes out in the clear.
+var debugRedacted = []string{"Authorization", "Proxy-Authorization"}
+
+// WithDebug writes every attempt's request and response to w as they go over
+// the wire, headers included, with bodies cut at 64 KiB. Authorization
+// headers are redacted. Each dump is a single Write, so w may be shared by
+// concurrent calls if its Write is safe for concurrent use.
+func WithDebug(w io.Writer) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.debug = w
+	}
+}
+
+// dumpRequest writes req to w and leaves req with an unread body.
+func dumpRequest(w io.Writer, req *http.Request) {
+	redacted := *req
+	redacted.Header = req.Header.Clone()
+
+	for _, key := range debugRedacted {
+		if redacted.Header.Get(key) != "" {
+			redacted.Header.Set(key, "[REDACTED]")
+		}
+	}
+
+	dump, err := httputil.DumpRequestOut(&redacted, true)
+	if err != nil {
+		_, _ = io.WriteString(w, "jsonrpc debug: dump request: "+err.Error()+"\n")
+		return
+	}
+
+	// the dump consumed the body and left a replayable copy behind
+	if req.Body != nil {
+		req.Body = redacted.Body
+	}
+
+	_, _ = w.Write(capDump(dump))
+}
+
+// dumpResponse writes the headers and the start of the body of resp to w,
+// putting the peeked bytes back in front of the rest of the body.
+func dumpResponse(w io.Writer, resp *http.Response) {
+	head, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		_, _ = io.WriteString(w, "jsonrpc debug: dump response: "+err.Error()+"\n")
+		return
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(resp.Body, debugBodyLimit+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), resp.Body), resp.Body}
+
+	_, _ = w.Write(capDump(append(head, peeked...)))
+
+	if err != nil {
+		_, _ = io.WriteString(w, "jsonrpc debug: read response: "+err.Error()+"\n")
+	}
+}
+
+// capDump cuts the body of an HTTP dump at debugBodyLimit.
+func capDump(dump []byte) []byte {
+	out := dump
+
+	if i := bytes.Index(dump, []byte("\r\n\r\n")); i >= 0 && len(dump)-i-4 > debugBodyLimit {
+		out = append(dump[:i+4+debugBodyLimit:i+4+debugBodyLimit], "\n... (truncated)"...)
+	}
+
+	return append(out, "\n\n"...)
+}
@@ -0,0 +1,79 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWithDebugDumpsWire(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Node", "bitcoind")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"0000abc","id":1}`)
+	}))
+	defer server.Close()
+
+	var dump bytes.Buffer
+
+	res, err := jsonrpc.NewRequest("getblockhash", []int{883189}, jsonrpc.WithRPCid[[]int, string](1)).
+		Prepare(server.URL, jsonrpc.WithDebug(&dump), jsonrpc.WithBearerToken("s3cr3t")).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "0000abc", *res)
+
+	out := dump.String()
+	require.Contains(t, out, "POST / HTTP/1.1")
+	require.Contains(t, out, "Authorization: [REDACTED]")
+	require.NotContains(t, out, "s3cr3t")
+	require.Contains(t, out, `"method":"getblockhash"`)
+	require.Contains(t, out, "HTTP/1.1 200 OK")
+	require.Contains(t, out, "X-Node: bitcoind")
+	require.Contains(t, out, `{"jsonrpc":"2.0","result":"0000abc","id":1}`)
+}
+
+func TestExecuteWithDebugShowsUndecodableBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{not-json`)
+	}))
+	defer server.Close()
+
+	var dump bytes.Buffer
+
+	_, err := jsonrpc.NewRequest[struct{}, string]("broken", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithDebug(&dump)).
+		Execute(server.Client())
+	require.ErrorContains(t, err, "decode response")
+	require.Contains(t, dump.String(), "{not-json")
+}
+
+func TestExecuteWithDebugTruncatesLargeBodies(t *testing.T) {
+	t.Parallel()
+
+	big := strings.Repeat("a", 128<<10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%q,"id":1}`, big)
+	}))
+	defer server.Close()
+
+	var dump bytes.Buffer
+
+	res, err := jsonrpc.NewRequest("echo", []string{big}, jsonrpc.WithRPCid[[]string, string](1)).
+		Prepare(server.URL, jsonrpc.WithDebug(&dump)).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, big, *res)
+
+	require.Equal(t, 2, strings.Count(dump.String(), "... (truncated)"))
+	require.Less(t, dump.Len(), 2*(64<<10)+4<<10)
+}
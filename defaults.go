@@ -0,0 +1,27 @@
+package jsonrpc
+
+import "sync"
+
+var defaults struct {
+	mu   sync.RWMutex
+	opts []PrepareOpt
+}
+
+// SetDefaults replaces the package-wide options applied by every Prepare.
+// They run before the per-call options, so a per-call option overrides a
+// default one. Calling SetDefaults without arguments clears them.
+func SetDefaults(opts ...PrepareOpt) {
+	cp := make([]PrepareOpt, len(opts))
+	copy(cp, opts)
+
+	defaults.mu.Lock()
+	defaults.opts = cp
+	defaults.mu.Unlock()
+}
+
+func defaultPrepareOpts() []PrepareOpt {
+	defaults.mu.RLock()
+	defer defaults.mu.RUnlock()
+
+	return defaults.opts
+}
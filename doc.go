@@ -0,0 +1,16 @@
+// Package jsonrpc is a typed JSON-RPC 2.0 client, with a matching server
+// Handler.
+//
+// A request is typed by its params and its result:
+//
+//	req := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{883189})
+//	hash, err := req.Prepare(url).Execute(nil)
+//
+// Call does the same in one step, and Client binds endpoints and options
+// shared by many calls.
+//
+// Migrating from v1: the v1 RPCRequest[Resp] built by CreateRequest and the
+// free Prepare and Execute functions have no counterpart here. Replace
+// CreateRequest(method, params) with NewRequest[Params, Resp](method, params)
+// followed by Prepare and Execute, or use Call. The wire format is the same.
+package jsonrpc
@@ -1,58 +1,784 @@
 package jsonrpc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
 
-	"github.com/bytedance/sonic"
 	"github.com/rotisserie/eris"
 )
 
-type praparedRPCRequest[Resp any] struct {
+// preparedHTTP is the transport part shared by prepared requests and batches.
+type preparedHTTP struct {
 	internal *http.Request
+	config   *prepareConfig
 	err      error
 }
 
+type praparedRPCRequest[Resp any] struct {
+	preparedHTTP
+	method   string
+	version  string
+	id       any
+	validate func(*Resp) error
+}
+
 type ExecuteOpt func(*http.Client)
 
+// DefaultMaxResponseBytes bounds response bodies unless WithMaxResponseBytes
+// says otherwise. It leaves plenty of room for verbose multi-MB blocks.
+const DefaultMaxResponseBytes int64 = 256 << 20
+
+var ErrResponseTooLarge = eris.New("response body exceeds limit")
+
+var ErrTransport = eris.New("transport failure")
+
+// TransportError reports a request that got no HTTP response at all, e.g.
+// on a DNS failure, a refused connection, a TLS error or a timeout. It
+// matches ErrTransport with errors.Is and unwraps to the *url.Error from
+// the HTTP client. A call canceled by its caller is not a TransportError.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Is(target error) bool {
+	return target == ErrTransport
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the request ran out of time, be it the context
+// deadline or a dial, TLS or response header timeout.
+func (e *TransportError) Timeout() bool {
+	var netErr net.Error
+
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// HTTPError is returned for responses with a non-2xx status. RetryAfter holds
+// the delay parsed from a Retry-After header, or zero when there was none.
+// Snippet holds the start of the body, usually the server's explanation,
+// e.g. "invalid API key".
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Snippet    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("http status %d: %q", e.StatusCode, e.Snippet)
+}
+
+// httpError reads the snippet of a non-2xx response, leaving the rest of the
+// body to the caller.
+func httpError(resp *http.Response) *HTTPError {
+	retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+	return &HTTPError{StatusCode: resp.StatusCode, RetryAfter: retryAfter, Snippet: bodySnippet(resp)}
+}
+
+var ErrUnexpectedContentType = eris.New("unexpected response content type")
+
+// ContentTypeError is returned under WithRequireJSONContentType for a 2xx
+// response that is not JSON. Snippet holds the start of the body, e.g. an
+// HTML error page or a plaintext message. It matches
+// ErrUnexpectedContentType with errors.Is.
+type ContentTypeError struct {
+	ContentType string
+	Snippet     string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected response content type %q: %q", e.ContentType, e.Snippet)
+}
+
+func (e *ContentTypeError) Is(target error) bool {
+	return target == ErrUnexpectedContentType
+}
+
+// snippetLimit bounds the body quoted by an HTTPError or ContentTypeError.
+const snippetLimit = 256
+
+// bodySnippet returns the start of the decoded body. The path and query of
+// the request URL are redacted, as servers may echo them and they often
+// carry API keys.
+func bodySnippet(resp *http.Response) string {
+	body, _, err := decodeContent(resp)
+	if err != nil {
+		return ""
+	}
+
+	raw, _ := io.ReadAll(io.LimitReader(body, snippetLimit))
+	snippet := strings.TrimSpace(strings.ToValidUTF8(string(raw), ""))
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		for _, secret := range []string{resp.Request.URL.RawQuery, resp.Request.URL.EscapedPath()} {
+			if len(secret) > 1 {
+				snippet = strings.ReplaceAll(snippet, secret, "[redacted]")
+			}
+		}
+	}
+
+	return snippet
+}
+
+// checkContentType accepts application/json, application/json-rpc and +json
+// media types, whatever their parameters, e.g. a charset. Other responses are
+// drained and closed.
+func checkContentType(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		(mediaType == "application/json" || mediaType == "application/json-rpc" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	snippet := bodySnippet(resp)
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return &ContentTypeError{ContentType: contentType, Snippet: snippet}
+}
+
+// rpcErrorProbe is the response envelope with the result reduced to whether
+// it was present and not null, so it is never materialised.
+type rpcErrorProbe struct {
+	JSONRPC string         `json:"jsonrpc"`
+	Result  resultPresence `json:"result"`
+	Error   *RPCError      `json:"error,omitempty"`
+	ID      any            `json:"id"`
+}
+
 func (rpc *praparedRPCRequest[Resp]) Execute(client *http.Client, opts ...ExecuteOpt) (*Resp, error) {
 	if rpc.err != nil {
 		return nil, eris.Wrap(rpc.err, "execute prepared request")
 	}
 
+	var result RPCResponse[Resp]
+
+	if err := rpc.execute(client, opts, &result); err != nil {
+		return nil, err
+	}
+
+	return &result.Result, nil
+}
+
+// Call creates, prepares and executes a one-shot request to url. client may
+// be nil to use the package's tuned default.
+func Call[Params any, Resp any](ctx context.Context, client *http.Client, url string, method string, params Params, opts ...PrepareOpt) (*Resp, error) {
+	opts = append([]PrepareOpt{WithContext(ctx)}, opts...)
+
+	return NewRequest[Params, Resp](method, params).Prepare(url, opts...).Execute(client)
+}
+
+// execute decodes into result, which may be pre-populated: a pointer put in
+// an interface-typed Result is decoded into rather than replaced.
+func (rpc *praparedRPCRequest[Resp]) execute(client *http.Client, opts []ExecuteOpt, result *RPCResponse[Resp]) error {
+	if _, err := rpc.roundTrip(client, opts, result); err != nil {
+		return rpc.wrap(err)
+	}
+
+	if result.Error != nil {
+		return rpc.wrap(result.Error)
+	}
+
+	return rpc.check(&result.Result)
+}
+
+// check runs the validator registered with WithValidate, if any.
+func (rpc *praparedRPCRequest[Resp]) check(result *Resp) error {
+	if rpc.validate == nil {
+		return nil
+	}
+
+	if err := rpc.validate(result); err != nil {
+		return rpc.wrap(eris.Wrap(err, "validate result"))
+	}
+
+	return nil
+}
+
+// wrap names the method and endpoint err came from, e.g.
+// "execute getblock on https://node: http status 500".
+func (rpc *praparedRPCRequest[Resp]) wrap(err error) error {
+	return eris.Wrapf(err, "execute %s on %s", rpc.method, rpc.endpoint())
+}
+
+// endpoint is the scheme and host the request goes to. Path and query are
+// left out as they often carry API keys.
+func (rpc *preparedHTTP) endpoint() string {
+	return rpc.internal.URL.Scheme + "://" + rpc.internal.URL.Host
+}
+
+// FullResponse is the outcome of ExecuteFull: the typed result or the RPC
+// error, along with the id and jsonrpc version the server echoed, the id as
+// decoded into an any, and the HTTP status and headers of the response.
+//
+// NullResult reports a null result, e.g. eth_getTransactionByHash for an
+// unknown hash. Result is still set then and holds the zero value, so for a
+// non-pointer Resp NullResult is the only way to tell null from {} or 0; a
+// pointer Resp is additionally left nil.
+//
+// Duration runs from just before the request is sent until the body is
+// decoded, rate-limit waits and retries included. It splits into
+// NetworkDuration, up to the body being read, and DecodeDuration.
+type FullResponse[Resp any] struct {
+	Result     *Resp
+	NullResult bool
+	Error      *RPCError
+	ID         any
+	JSONRPC    string
+	StatusCode int
+	Header     http.Header
+
+	Duration        time.Duration
+	NetworkDuration time.Duration
+	DecodeDuration  time.Duration
+}
+
+// ExecuteFull is like Execute but also exposes the HTTP status and headers,
+// e.g. provider rate-limit headers. A JSON-RPC error is reported in the
+// Error field, not as the returned error. When the HTTP status is not 2xx
+// both the error and a FullResponse holding the status and headers are
+// returned.
+func (rpc *praparedRPCRequest[Resp]) ExecuteFull(client *http.Client, opts ...ExecuteOpt) (*FullResponse[Resp], error) {
+	if rpc.err != nil {
+		return nil, eris.Wrap(rpc.err, "execute prepared request")
+	}
+
+	var result RPCResponse[Resp]
+
+	resp, err := rpc.roundTrip(client, opts, &result)
+
+	var full *FullResponse[Resp]
+	if resp != nil {
+		full = &FullResponse[Resp]{
+			StatusCode:      resp.StatusCode,
+			Header:          resp.Header,
+			Duration:        result.elapsed,
+			NetworkDuration: result.elapsed - result.decoding,
+			DecodeDuration:  result.decoding,
+		}
+	}
+
+	if err != nil {
+		return full, rpc.wrap(err)
+	}
+
+	full.ID = result.ID
+	full.JSONRPC = result.JSONRPC
+
+	if result.Error != nil {
+		full.Error = result.Error
+	} else {
+		full.Result = &result.Result
+		full.NullResult = result.null
+	}
+
+	if full.Error == nil {
+		if err := rpc.check(full.Result); err != nil {
+			return full, err
+		}
+	}
+
+	return full, nil
+}
+
+// roundTrip sends the request and decodes the response envelope into result.
+// A JSON-RPC error is left in result.Error for the caller to report.
+func (rpc *praparedRPCRequest[Resp]) roundTrip(client *http.Client, opts []ExecuteOpt, result *RPCResponse[Resp]) (*http.Response, error) {
+	release, err := rpc.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// one deadline covers every attempt, so retries by code cannot stretch
+	// the call past WithTimeout
+	req, cancel := rpc.withTimeout(rpc.internal)
+	defer cancel()
+
+	if rpc.config.tracer == nil {
+		return rpc.exchangeRetrying(req, client, opts, result)
+	}
+
+	ctx, span := rpc.config.tracer.Start(req.Context(), rpc.method)
+	span.SetAttribute(AttrRPCSystem, "jsonrpc")
+	span.SetAttribute(AttrRPCMethod, rpc.method)
+	span.SetAttribute(AttrRPCRequestID, idKey(rpc.id))
+
+	resp, err := rpc.exchangeRetrying(req.WithContext(ctx), client, opts, result)
+	if resp != nil {
+		span.SetAttribute(AttrHTTPStatusCode, resp.StatusCode)
+	}
+
+	spanErr := err
+	if err == nil && result.Error != nil {
+		span.SetAttribute(AttrRPCErrorCode, result.Error.Code)
+		spanErr = result.Error
+	}
+
+	span.End(spanErr)
+
+	return resp, err
+}
+
+// exchangeRetrying repeats the exchange while the server answers with an
+// RPC error whose code WithRetryableCodes lists. The retries reuse the
+// request as modified by WithBeforeSend.
+func (rpc *praparedRPCRequest[Resp]) exchangeRetrying(req *http.Request, client *http.Client, opts []ExecuteOpt, result *RPCResponse[Resp]) (*http.Response, error) {
+	req, err := rpc.beforeSend(req, rpc.method)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := rpc.exchange(req, client, opts, result)
+		if err != nil || !rpc.config.shouldRetryCode(req.Context(), attempt, result.Error) {
+			return resp, err
+		}
+
+		delay := rpc.config.retryDelay(attempt, result.Error)
+		if !retryFits(req.Context(), delay) {
+			return resp, nil
+		}
+
+		if err := waitRetry(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		result.Error = nil
+	}
+}
+
+func (rpc *praparedRPCRequest[Resp]) exchange(req *http.Request, client *http.Client, opts []ExecuteOpt, result *RPCResponse[Resp]) (resp *http.Response, err error) {
+	if rpc.config.metrics != nil {
+		done := rpc.startMetrics()
+		defer func() {
+			done(err, result.Error)
+		}()
+	}
+
+	var call *hookCall
+	if rpc.config.hook != nil {
+		call = rpc.startHook(req)
+		defer func() {
+			call.finish(resp, err, result.Error)
+		}()
+	}
+
+	start := time.Now()
+	defer func() {
+		result.elapsed = time.Since(start)
+	}()
+
+	resp, err = rpc.do(req, client, opts)
+	if err != nil {
+		return resp, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if call != nil {
+		call.tap(resp, rpc.config.hookBodies)
+	}
+
+	decode := rpc.decode
+	if result.each != nil {
+		decode = rpc.decodeEach
+	}
+
+	if err := decode(resp, result); err != nil {
+		return resp, err
+	}
+
+	if rpc.config.onVersionMismatch != nil && result.JSONRPC != rpc.version {
+		rpc.config.onVersionMismatch(result.JSONRPC)
+	}
+
+	return resp, rpc.config.checkVersion(result.ID, result.JSONRPC)
+}
+
+// ExecuteNotification sends the request and only checks the HTTP status;
+// the response body, if any, is discarded without decoding.
+func (rpc *praparedRPCRequest[Resp]) ExecuteNotification(client *http.Client, opts ...ExecuteOpt) error {
+	if rpc.err != nil {
+		return eris.Wrap(rpc.err, "execute prepared notification")
+	}
+
+	release, err := rpc.acquire()
+	if err != nil {
+		return rpc.wrap(err)
+	}
+	defer release()
+
+	req, err := rpc.beforeSend(rpc.internal, rpc.method)
+	if err != nil {
+		return rpc.wrap(err)
+	}
+
+	resp, err := rpc.doRequest(req, client, opts)
+	if err != nil {
+		return rpc.wrap(err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// doRequest sends req bounded by WithTimeout; the deadline is released when
+// the response body is closed.
+func (rpc *preparedHTTP) doRequest(req *http.Request, client *http.Client, opts []ExecuteOpt) (*http.Response, error) {
+	req, cancel := rpc.withTimeout(req)
+
+	resp, err := rpc.do(req, client, opts)
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	// the deadline must cover reading the body, so release it on close
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// withTimeout bounds req by WithTimeout, or by WithDefaultTimeout when its
+// context has no deadline.
+func (rpc *preparedHTTP) withTimeout(req *http.Request) (*http.Request, context.CancelFunc) {
+	timeout := rpc.config.timeout
+	if _, ok := req.Context().Deadline(); !ok && timeout <= 0 {
+		timeout = rpc.config.defaultTimeout
+	}
+
+	if timeout <= 0 {
+		return req, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	return req.WithContext(ctx), cancel
+}
+
+// do sends req through client, or the default client, with opts applied.
+func (rpc *preparedHTTP) do(req *http.Request, client *http.Client, opts []ExecuteOpt) (*http.Response, error) {
 	cli := client
 	if client == nil {
 		cli = defaultHTTPClient
+
+		// options must not leak into the shared client; the copy still
+		// shares the tuned transport and its connection pool
+		if len(opts) > 0 {
+			clone := *defaultHTTPClient
+			cli = &clone
+		}
 	}
 
 	for _, opt := range opts {
 		opt(cli)
 	}
 
-	resp, err := cli.Do(rpc.internal)
+	if rpc.config.clientTrace != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), rpc.config.clientTrace))
+	}
+
+	return rpc.send(cli, req)
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+
+	return err
+}
+
+// send makes the attempts, waiting on the rate limiter and between retries.
+func (rpc *preparedHTTP) send(cli *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if rpc.config.limiter != nil {
+			if err := rpc.config.limiter.Wait(req.Context()); err != nil {
+				return nil, eris.Wrap(err, "rate limit")
+			}
+		}
+
+		resp, err := rpc.attempt(cli, req)
+		if err == nil || !rpc.config.shouldRetry(req.Context(), attempt, resp, err) {
+			return resp, err
+		}
+
+		// a wait past the deadline could only end in a context error, so
+		// the failure at hand is reported instead
+		delay := rpc.config.retryDelay(attempt, err)
+		if !retryFits(req.Context(), delay) {
+			return resp, err
+		}
+
+		if err := waitRetry(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (rpc *preparedHTTP) attempt(cli *http.Client, req *http.Request) (*http.Response, error) {
+	// the body reader is consumed by every send, so each attempt works on a
+	// shallow copy with a fresh one
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, eris.Wrap(err, "get body")
+		}
+
+		req = req.WithContext(req.Context())
+		req.Body = body
+	}
+
+	if rpc.config.signer != nil {
+		signed, err := signRequest(req, rpc.config.signer)
+		if err != nil {
+			return nil, err
+		}
+
+		req = signed
+	}
+
+	if rpc.config.debug != nil {
+		dumpRequest(rpc.config.debug, req)
+	}
+
+	resp, err := cli.Do(req)
 	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			err = &TransportError{Err: err}
+		}
+
 		return nil, eris.Wrap(err, "execute req")
 	}
 
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	if rpc.config.debug != nil {
+		dumpResponse(rpc.config.debug, resp)
+	}
+
+	if rpc.config.afterResponse != nil {
+		if err := rpc.config.afterResponse(resp); err != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			return resp, eris.Wrap(err, "after response")
+		}
+	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httpErr := httpError(resp)
 		_, _ = io.Copy(io.Discard, resp.Body)
-		return nil, eris.Errorf("http status %d", resp.StatusCode)
+		_ = resp.Body.Close()
+		// the drained response is returned so callers can still inspect
+		// the status and headers
+		return resp, httpErr
 	}
 
-	var result RPCResponse[Resp]
+	if rpc.config.requireJSON {
+		if err := checkContentType(resp); err != nil {
+			return resp, eris.Wrap(err, "check response")
+		}
+	}
 
-	decoder := sonic.ConfigDefault.NewDecoder(resp.Body)
-	if err := decoder.Decode(&result); err != nil {
-		return nil, eris.Wrap(err, "decode response")
+	return resp, nil
+}
+
+// decode probes the envelope for an error first, skipping over the result,
+// and only decodes the result into Resp when no error is present. A failed
+// call carrying a large stale result therefore does not pay for it.
+func (rpc *praparedRPCRequest[Resp]) decode(resp *http.Response, result *RPCResponse[Resp]) error {
+	raw, err := rpc.readBody(resp)
+	if err != nil {
+		return err
 	}
 
-	if result.Error != nil {
-		return nil, result.Error
+	start := time.Now()
+	defer func() {
+		result.decoding = time.Since(start)
+	}()
+
+	var probe rpcErrorProbe
+	if err := unmarshalString(rpc.config.codec, raw, &probe); err != nil {
+		return eris.Wrap(err, "decode response")
 	}
 
-	return &result.Result, nil
+	if probe.Error != nil {
+		if probe.Result {
+			return &AmbiguousResponseError{ID: probe.ID, Err: probe.Error}
+		}
+
+		result.JSONRPC = probe.JSONRPC
+		result.Error = probe.Error
+		result.ID = probe.ID
+
+		return nil
+	}
+
+	if rpc.config.strictDecode {
+		if err := rpc.decodeStrict(raw, &probe, result); err != nil {
+			return err
+		}
+	} else if err := unmarshalString(rpc.config.codec, raw, result); err != nil {
+		return resultDecodeError(rpc.config.codec, raw, err)
+	}
+
+	result.null = !bool(probe.Result)
+
+	return nil
+}
+
+// decodeStrict decodes only the result strictly, taking the envelope
+// members from the probe.
+func (rpc *praparedRPCRequest[Resp]) decodeStrict(raw string, probe *rpcErrorProbe, result *RPCResponse[Resp]) error {
+	var env struct {
+		Result json.RawMessage `json:"result"`
+	}
+
+	if err := unmarshalString(rpc.config.codec, raw, &env); err != nil {
+		return eris.Wrap(err, "decode response")
+	}
+
+	result.JSONRPC = probe.JSONRPC
+	result.ID = probe.ID
+
+	if len(env.Result) == 0 {
+		return nil
+	}
+
+	if err := unmarshalStrict(rpc.config.codec, env.Result, &result.Result); err != nil {
+		return eris.Wrap(&ResultDecodeError{Result: env.Result, Err: err}, "decode response result")
+	}
+
+	return nil
+}
+
+func (rpc *preparedHTTP) readBody(resp *http.Response) (string, error) {
+	body, decoded, err := decodeContent(resp)
+	if err != nil {
+		return "", eris.Wrap(err, "read response")
+	}
+
+	// the announced length is the encoded one, useless as a size hint
+	sizeHint := resp.ContentLength
+	if decoded {
+		sizeHint = -1
+	}
+
+	// the server may announce far more than it sends, so the announcement
+	// sizes the buffer only up to a bound and growth covers the rest
+	sizeHint = min(sizeHint, max(int64(rpc.config.responseBufferSize), maxPreallocBytes))
+
+	if sizeHint <= 0 {
+		sizeHint = int64(rpc.config.responseBufferSize)
+	}
+
+	if limit := rpc.config.maxResponseBytes; limit > 0 {
+		if resp.ContentLength > limit {
+			return "", eris.Wrapf(ErrResponseTooLarge, "read response: %d bytes announced, limit %d", resp.ContentLength, limit)
+		}
+
+		// one extra byte tells a body of exactly limit bytes from a longer
+		// one; applied after decoding so the limit bounds the decoded size
+		body = io.LimitReader(body, limit+1)
+		sizeHint = min(sizeHint, limit+1)
+	}
+
+	// the transport fails body reads once the request context is done, so
+	// a slowly dribbling body is aborted by the caller's deadline
+	raw, err := readAll(body, sizeHint)
+	if err != nil {
+		return "", eris.Wrap(err, "read response")
+	}
+
+	if limit := rpc.config.maxResponseBytes; limit > 0 && int64(len(raw)) > limit {
+		return "", eris.Wrapf(ErrResponseTooLarge, "read response: limit %d bytes", limit)
+	}
+
+	if rpc.config.preprocess != nil {
+		fixed, err := rpc.config.preprocess([]byte(raw))
+		if err != nil {
+			return "", eris.Wrap(err, "preprocess response")
+		}
+
+		raw = string(fixed)
+	}
+
+	return raw, nil
+}
+
+// maxPreallocBytes bounds the buffer allocated up front for a response of
+// announced length, unless WithResponseBufferSize asks for more.
+const maxPreallocBytes = 4 << 20
+
+// readAll reads the body into a string so both decode passes can share it
+// without copying. Reads go straight into the spare capacity of the buffer,
+// which doubles when full, and the buffer becomes the string as
+// strings.Builder does, so the body is held once. A full buffer is probed
+// before growing, so a body of exactly sizeHint bytes is not doubled just
+// to read EOF.
+func readAll(r io.Reader, sizeHint int64) (string, error) {
+	buf := make([]byte, 0, max(sizeHint, 512))
+
+	for {
+		if len(buf) == cap(buf) {
+			var probe [512]byte
+
+			n, err := r.Read(probe[:])
+			if n > 0 {
+				buf = append(slices.Grow(buf, max(cap(buf), 32<<10)), probe[:n]...)
+			}
+
+			if err == io.EOF {
+				return unsafe.String(unsafe.SliceData(buf), len(buf)), nil
+			}
+
+			if err != nil {
+				return "", err
+			}
+
+			continue
+		}
+
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		if err == io.EOF {
+			return unsafe.String(unsafe.SliceData(buf), len(buf)), nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
 }
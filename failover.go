@@ -0,0 +1,119 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// EndpointSelector decides in which order a call tries the client's
+// endpoints.
+type EndpointSelector interface {
+	Order(endpoints []string) []string
+}
+
+// RoundRobin starts every call at the endpoint after the one the previous
+// call started at. The zero value is ready to use.
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (r *RoundRobin) Order(endpoints []string) []string {
+	start := int((r.next.Add(1) - 1) % uint64(len(endpoints)))
+
+	ordered := make([]string, 0, len(endpoints))
+	ordered = append(ordered, endpoints[start:]...)
+
+	return append(ordered, endpoints[:start]...)
+}
+
+// WithEndpoints makes the client fail over between urls, which replace the
+// URL given to NewClient. A call moves to the next endpoint only after the
+// current one failed in transport or with a 5xx status, retries included.
+// An empty list keeps the NewClient URL.
+func WithEndpoints(urls []string) ClientOption {
+	return func(c *Client) {
+		if len(urls) > 0 {
+			c.endpoints = urls
+		}
+	}
+}
+
+func WithEndpointSelector(s EndpointSelector) ClientOption {
+	return func(c *Client) {
+		c.selector = s
+	}
+}
+
+type EndpointError struct {
+	URL string
+	Err error
+}
+
+// EndpointsError is returned when every endpoint of a client failed. It
+// unwraps to the individual failures.
+type EndpointsError struct {
+	Errors []EndpointError
+}
+
+func (e *EndpointsError) Error() string {
+	var b strings.Builder
+	b.WriteString("all endpoints failed")
+
+	for i, failure := range e.Errors {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+
+		b.WriteString(failure.URL)
+		b.WriteString(": ")
+		b.WriteString(failure.Err.Error())
+	}
+
+	return b.String()
+}
+
+func (e *EndpointsError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, failure := range e.Errors {
+		errs[i] = failure.Err
+	}
+
+	return errs
+}
+
+func (c *Client) failover(ctx context.Context, call func(url string) error) error {
+	if len(c.endpoints) == 1 {
+		return call(c.endpoints[0])
+	}
+
+	var failures []EndpointError
+
+	for _, endpoint := range c.selector.Order(c.endpoints) {
+		err := call(endpoint)
+		if err == nil || ctx.Err() != nil || !isEndpointFailure(err) {
+			return err
+		}
+
+		failures = append(failures, EndpointError{URL: endpoint, Err: err})
+	}
+
+	return &EndpointsError{Errors: failures}
+}
+
+func isEndpointFailure(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// http.Client reports every transport failure as *url.Error
+	var urlErr *url.Error
+
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,42 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/rotisserie/eris"
+)
+
+// MaxGETURLLength is the longest URL a GET request may produce; it stays
+// below the header limits of common proxies and CDNs.
+const MaxGETURLLength = 8 << 10
+
+var (
+	ErrGETNotAllowed = eris.New("GET is only allowed for requests with an id")
+	ErrURLTooLong    = eris.New("request does not fit in a GET URL")
+)
+
+// WithHTTPMethod selects how the request is sent: http.MethodPost (default)
+// puts it in the body, http.MethodGet in the "request" query parameter, so
+// idempotent reads can be cached by gateways and CDNs. GET is refused for
+// notifications and for URLs longer than MaxGETURLLength.
+func WithHTTPMethod(method string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.httpMethod = method
+	}
+}
+
+func setQuery(req *http.Request, payload []byte) error {
+	query := req.URL.Query()
+	query.Set("request", string(bytes.TrimRight(payload, "\n")))
+
+	req.Method = http.MethodGet
+	req.URL.RawQuery = query.Encode()
+	req.Header.Del("Content-Type")
+
+	if n := len(req.URL.String()); n > MaxGETURLLength {
+		return eris.Wrapf(ErrURLTooLong, "prepare get request: %d bytes, limit %d", n, MaxGETURLLength)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package jsonrpc_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWithHTTPMethodGET(t *testing.T) {
+	t.Parallel()
+
+	type seenRequest struct {
+		method string
+		query  string
+		length int64
+		chain  string
+	}
+
+	seen := make(chan seenRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- seenRequest{
+			method: r.Method,
+			query:  r.URL.Query().Get("request"),
+			length: r.ContentLength,
+			chain:  r.URL.Query().Get("chain"),
+		}
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":883189,"id":1}`)
+	}))
+	defer server.Close()
+
+	res, err := jsonrpc.NewRequest[[]int, int]("getblockhash", []int{883189}, jsonrpc.WithRPCid[[]int, int](1)).
+		Prepare(server.URL+"?chain=btc", jsonrpc.WithHTTPMethod(http.MethodGet)).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, 883189, *res)
+
+	got := <-seen
+	require.Equal(t, http.MethodGet, got.method)
+	require.Zero(t, got.length)
+	require.Equal(t, "btc", got.chain)
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"getblockhash","params":[883189],"id":1}`, got.query)
+}
+
+func TestPrepareWithHTTPMethodGETRefusals(t *testing.T) {
+	t.Parallel()
+
+	_, err := jsonrpc.NewNotification("ping", []int{}).
+		Prepare("http://localhost", jsonrpc.WithHTTPMethod(http.MethodGet)).
+		Execute(nil)
+	require.ErrorIs(t, err, jsonrpc.ErrGETNotAllowed)
+
+	long := []string{strings.Repeat("a", jsonrpc.MaxGETURLLength)}
+	_, err = jsonrpc.NewRequest[[]string, string]("getrawtransaction", long).
+		Prepare("http://localhost", jsonrpc.WithHTTPMethod(http.MethodGet)).
+		Execute(nil)
+	require.ErrorIs(t, err, jsonrpc.ErrURLTooLong)
+
+	_, err = jsonrpc.NewRequest[[]string, string]("getrawtransaction", nil).
+		Prepare("http://localhost", jsonrpc.WithHTTPMethod(http.MethodPut)).
+		Execute(nil)
+	require.ErrorContains(t, err, `unsupported http method "PUT"`)
+}
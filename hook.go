@@ -0,0 +1,164 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// Hook observes executed requests, e.g. for structured logging. Bodies are
+// only captured when WithHookBodies is given, as they can contain secrets.
+type Hook interface {
+	OnRequest(info RequestInfo)
+	OnResponse(info ResponseInfo)
+}
+
+// RequestInfo describes a call about to be sent. URL holds only the scheme
+// and host of the endpoint, as path and query often carry API keys.
+type RequestInfo struct {
+	Method    string
+	ID        any
+	URL       string
+	BytesSent int64
+	Body      []byte
+}
+
+// ResponseInfo describes the outcome of a call. Err is the error the call
+// failed with, a JSON-RPC error included; it is nil on success.
+type ResponseInfo struct {
+	RequestInfo
+	StatusCode    int
+	BytesReceived int64
+	Duration      time.Duration
+	Err           error
+	Body          []byte
+}
+
+func WithHook(h Hook) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.hook = h
+	}
+}
+
+func WithHookBodies() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.hookBodies = true
+	}
+}
+
+// WithBeforeSend calls fn once per execution, before any network I/O, with
+// the call's context, the method, empty for a batch, and a copy of the HTTP
+// request to modify, e.g. setting headers from context values such as a
+// trace id or tenant. Retries reuse the modified request. An error from fn
+// aborts the call.
+func WithBeforeSend(fn func(ctx context.Context, method string, req *http.Request) error) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.beforeSend = fn
+	}
+}
+
+// WithAfterResponse calls fn with every response received, before its status
+// is checked and its body read. An error from fn fails the attempt instead
+// of decoding, e.g. to report a text/html error page as such rather than as
+// a decode error; it is retried only if the status is retryable. fn must not
+// read the body.
+func WithAfterResponse(fn func(resp *http.Response) error) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.afterResponse = fn
+	}
+}
+
+// beforeSend returns the request to send, as modified by the WithBeforeSend
+// hook. It works on a copy as the prepared request may run concurrently.
+func (rpc *preparedHTTP) beforeSend(req *http.Request, method string) (*http.Request, error) {
+	if rpc.config.beforeSend == nil {
+		return req, nil
+	}
+
+	req = req.Clone(req.Context())
+	if err := rpc.config.beforeSend(req.Context(), method, req); err != nil {
+		return nil, eris.Wrap(err, "before send")
+	}
+
+	return req, nil
+}
+
+type hookCall struct {
+	hook    Hook
+	info    ResponseInfo
+	start   time.Time
+	counter *countingBody
+}
+
+func (rpc *praparedRPCRequest[Resp]) startHook(req *http.Request) *hookCall {
+	call := &hookCall{hook: rpc.config.hook}
+	call.info.RequestInfo = RequestInfo{
+		Method:    rpc.method,
+		ID:        rpc.id,
+		URL:       rpc.endpoint(),
+		BytesSent: req.ContentLength,
+	}
+
+	if rpc.config.hookBodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			call.info.RequestInfo.Body, _ = io.ReadAll(body)
+		}
+	}
+
+	call.hook.OnRequest(call.info.RequestInfo)
+	call.start = time.Now()
+
+	return call
+}
+
+func (call *hookCall) tap(resp *http.Response, captureBody bool) {
+	call.counter = &countingBody{ReadCloser: resp.Body}
+	if captureBody {
+		call.counter.capture = &bytes.Buffer{}
+	}
+
+	resp.Body = call.counter
+}
+
+func (call *hookCall) finish(resp *http.Response, err error, rpcErr *RPCError) {
+	call.info.Duration = time.Since(call.start)
+	call.info.Err = err
+
+	if err == nil && rpcErr != nil {
+		call.info.Err = rpcErr
+	}
+
+	if resp != nil {
+		call.info.StatusCode = resp.StatusCode
+	}
+
+	if call.counter != nil {
+		call.info.BytesReceived = call.counter.n
+		if call.counter.capture != nil {
+			call.info.Body = call.counter.capture.Bytes()
+		}
+	}
+
+	call.hook.OnResponse(call.info)
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	capture *bytes.Buffer
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+
+	if b.capture != nil {
+		b.capture.Write(p[:n])
+	}
+
+	return n, err
+}
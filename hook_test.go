@@ -0,0 +1,204 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingHook struct {
+	requests  []jsonrpc.RequestInfo
+	responses []jsonrpc.ResponseInfo
+}
+
+func (h *recordingHook) OnRequest(info jsonrpc.RequestInfo) {
+	h.requests = append(h.requests, info)
+}
+
+func (h *recordingHook) OnResponse(info jsonrpc.ResponseInfo) {
+	h.responses = append(h.responses, info)
+}
+
+const hookResponse = `{"jsonrpc":"2.0","result":"ok","id":"1"}`
+
+func newHookServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"bad params"},"id":"1"}`)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, hookResponse)
+		}
+	}))
+}
+
+func TestExecuteWithHook(t *testing.T) {
+	t.Parallel()
+
+	server := newHookServer()
+	defer server.Close()
+
+	hook := &recordingHook{}
+	req := jsonrpc.NewRequest[[]string, string]("getblock", []string{"secret"}, jsonrpc.WithRPCid[[]string, string]("1"))
+	body := `{"jsonrpc":"2.0","method":"getblock","params":["secret"],"id":"1"}` + "\n"
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithHook(hook)).Execute(server.Client())
+	require.NoError(t, err)
+
+	require.Len(t, hook.requests, 1)
+	require.Equal(t, jsonrpc.RequestInfo{
+		Method:    "getblock",
+		ID:        "1",
+		URL:       server.URL,
+		BytesSent: int64(len(body)),
+	}, hook.requests[0])
+
+	require.Len(t, hook.responses, 1)
+	res := hook.responses[0]
+	require.Equal(t, hook.requests[0], res.RequestInfo)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.EqualValues(t, len(hookResponse), res.BytesReceived)
+	require.Positive(t, res.Duration)
+	require.NoError(t, res.Err)
+	require.Nil(t, res.Body)
+}
+
+func TestExecuteWithHookBodies(t *testing.T) {
+	t.Parallel()
+
+	server := newHookServer()
+	defer server.Close()
+
+	hook := &recordingHook{}
+	req := jsonrpc.NewRequest[[]string, string]("getblock", []string{"secret"}, jsonrpc.WithRPCid[[]string, string]("1"))
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithHook(hook), jsonrpc.WithHookBodies()).Execute(server.Client())
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"getblock","params":["secret"],"id":"1"}`, string(hook.requests[0].Body))
+	require.Equal(t, hookResponse, string(hook.responses[0].Body))
+}
+
+func TestExecuteWithHookReportsFailures(t *testing.T) {
+	t.Parallel()
+
+	server := newHookServer()
+	defer server.Close()
+
+	hook := &recordingHook{}
+	req := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{})
+
+	_, err := req.Prepare(server.URL+"/error", jsonrpc.WithHook(hook)).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrInvalidParams)
+
+	_, err = req.Prepare(server.URL+"/down", jsonrpc.WithHook(hook)).Execute(server.Client())
+	require.Error(t, err)
+
+	require.Len(t, hook.responses, 2)
+	require.ErrorIs(t, hook.responses[0].Err, jsonrpc.ErrInvalidParams)
+
+	// the path is left out, as it may carry an API key
+	require.Equal(t, server.URL, hook.requests[0].URL)
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, hook.responses[1].Err, &httpErr)
+	require.Equal(t, http.StatusServiceUnavailable, hook.responses[1].StatusCode)
+}
+
+type tenantKey struct{}
+
+func TestExecuteWithBeforeSend(t *testing.T) {
+	t.Parallel()
+
+	tenants := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants <- r.Header.Get("X-Tenant")
+		fmt.Fprint(w, hookResponse)
+	}))
+	defer server.Close()
+
+	var methods []string
+	prepared := jsonrpc.NewRequest[struct{}, string]("ping", struct{}{}, jsonrpc.WithRPCid[struct{}, string]("1")).
+		Prepare(server.URL,
+			jsonrpc.WithContext(context.WithValue(context.Background(), tenantKey{}, "acme")),
+			jsonrpc.WithBeforeSend(func(ctx context.Context, method string, req *http.Request) error {
+				methods = append(methods, method)
+				req.Header.Set("X-Tenant", ctx.Value(tenantKey{}).(string))
+				return nil
+			}),
+		)
+
+	for range 2 {
+		res, err := prepared.Execute(server.Client())
+		require.NoError(t, err)
+		require.Equal(t, "ok", *res)
+		require.Equal(t, "acme", <-tenants)
+	}
+
+	require.Equal(t, []string{"ping", "ping"}, methods)
+}
+
+func TestExecuteWithBeforeSendAborts(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, hookResponse)
+	}))
+	defer server.Close()
+
+	denied := errors.New("tenant missing")
+	abort := jsonrpc.WithBeforeSend(func(context.Context, string, *http.Request) error {
+		return denied
+	})
+
+	_, err := jsonrpc.NewRequest[struct{}, string]("ping", struct{}{}).
+		Prepare(server.URL, abort, jsonrpc.WithRetry(3, nil)).
+		Execute(server.Client())
+	require.ErrorIs(t, err, denied)
+
+	err = jsonrpc.NewNotification[struct{}]("ping", struct{}{}).
+		Prepare(server.URL, abort).
+		ExecuteNotification(server.Client())
+	require.ErrorIs(t, err, denied)
+
+	_, err = jsonrpc.NewBatch(jsonrpc.NewRequest[struct{}, string]("ping", struct{}{})).
+		Prepare(server.URL, abort).
+		Execute(server.Client())
+	require.ErrorIs(t, err, denied)
+
+	require.Zero(t, hits.Load())
+}
+
+func TestExecuteWithAfterResponse(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>Bad gateway</body></html>")
+	}))
+	defer server.Close()
+
+	notJSON := errors.New("not a json response")
+
+	full, err := jsonrpc.NewRequest[struct{}, string]("ping", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithAfterResponse(func(resp *http.Response) error {
+			if resp.Header.Get("Content-Type") == "text/html" {
+				return notJSON
+			}
+			return nil
+		})).
+		ExecuteFull(server.Client())
+	require.ErrorIs(t, err, notJSON)
+	require.Equal(t, http.StatusOK, full.StatusCode)
+}
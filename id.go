@@ -0,0 +1,38 @@
+package jsonrpc
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type IDGenerator interface {
+	NextID() any
+}
+
+// CounterIDGenerator hands out increasing ids encoded as strings. It is safe
+// for concurrent use.
+type CounterIDGenerator struct {
+	n atomic.Uint64
+}
+
+func NewCounterIDGenerator(start uint64) *CounterIDGenerator {
+	g := &CounterIDGenerator{}
+	g.n.Store(start)
+
+	return g
+}
+
+func (g *CounterIDGenerator) NextID() any {
+	return strconv.FormatUint(g.n.Add(1), 10)
+}
+
+// seeded with the clock so ids keep looking like the former timestamps and
+// stay distinct across process restarts
+var defaultIDGenerator IDGenerator = NewCounterIDGenerator(uint64(time.Now().UnixNano()))
+
+func WithIDGenerator[Params any, Resp any](gen IDGenerator) RPCOpt[Params, Resp] {
+	return func(req *rpcRequest[Params, Resp]) {
+		req.ID = gen.NextID()
+	}
+}
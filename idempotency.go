@@ -0,0 +1,35 @@
+package jsonrpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// IdempotencyKeyHeader is the header gateways use to dedupe retried writes.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey sends key with every attempt of the request, so a
+// gateway that already processed it, e.g. a transaction broadcast whose
+// response was lost, does not process it again.
+func WithIdempotencyKey(key string) PrepareOpt {
+	return WithHeader(IdempotencyKeyHeader, key)
+}
+
+// WithContentIdempotencyKey derives the idempotency key from a SHA-256 of
+// the encoded request when retries are enabled with WithRetry. The request
+// id is part of the hash, so only retries of the same request share a key.
+// A key set with WithIdempotencyKey takes precedence.
+func WithContentIdempotencyKey() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.contentIdempotencyKey = true
+	}
+}
+
+func setContentIdempotencyKey(cfg *prepareConfig, body []byte) {
+	if !cfg.contentIdempotencyKey || cfg.retry == nil || cfg.request.Header.Get(IdempotencyKeyHeader) != "" {
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	cfg.request.Header.Set(IdempotencyKeyHeader, hex.EncodeToString(sum[:]))
+}
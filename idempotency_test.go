@@ -0,0 +1,86 @@
+package jsonrpc_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeyRecordingServer fails the first failures attempts with 503 and
+// records the idempotency key and body of every attempt.
+func newKeyRecordingServer(failures int32) (*httptest.Server, chan [2]string) {
+	var attempts atomic.Int32
+	seen := make(chan [2]string, 16)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen <- [2]string{r.Header.Get(jsonrpc.IdempotencyKeyHeader), string(body)}
+
+		if attempts.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}`))
+	}))
+
+	return server, seen
+}
+
+func TestIdempotencyKeyIsStableAcrossRetries(t *testing.T) {
+	t.Parallel()
+
+	server, seen := newKeyRecordingServer(2)
+	defer server.Close()
+
+	retry := jsonrpc.WithRetry(3, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))
+
+	_, err := jsonrpc.NewRequest[[]string, string]("sendrawtransaction", []string{"0200"}).
+		Prepare(server.URL, retry, jsonrpc.WithIdempotencyKey("tx-1")).
+		Execute(server.Client())
+	require.NoError(t, err)
+
+	close(seen)
+	require.Len(t, seen, 3)
+
+	for attempt := range seen {
+		require.Equal(t, "tx-1", attempt[0])
+	}
+}
+
+func TestContentIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	server, seen := newKeyRecordingServer(1)
+	defer server.Close()
+
+	retry := jsonrpc.WithRetry(2, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))
+	req := jsonrpc.NewRequest[[]string, string]("sendrawtransaction", []string{"0200"})
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithContentIdempotencyKey(), retry).Execute(server.Client())
+	require.NoError(t, err)
+
+	first, second := <-seen, <-seen
+	sum := sha256.Sum256([]byte(first[1]))
+	require.Equal(t, hex.EncodeToString(sum[:]), first[0])
+	require.Equal(t, first[0], second[0])
+
+	// an explicit key wins over the derived one
+	_, err = req.Prepare(server.URL, retry, jsonrpc.WithContentIdempotencyKey(), jsonrpc.WithIdempotencyKey("tx-1")).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "tx-1", (<-seen)[0])
+
+	// without retries there is nothing to dedupe
+	_, err = req.Prepare(server.URL, jsonrpc.WithContentIdempotencyKey()).Execute(server.Client())
+	require.NoError(t, err)
+	require.Empty(t, (<-seen)[0])
+}
@@ -1,6 +1,9 @@
 package jsonrpc_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -12,7 +15,17 @@ import (
 
 var benchmarkResult *types.Block
 
+// BenchmarkExecuteLargeResponse serves the fixture chunked, as nodes
+// usually do for large blocks, so the response size is not announced.
 func BenchmarkExecuteLargeResponse(b *testing.B) {
+	benchmarkLargeResponse(b)
+}
+
+func BenchmarkExecuteLargeResponseBuffered(b *testing.B) {
+	benchmarkLargeResponse(b, jsonrpc.WithResponseBufferSize(256<<10))
+}
+
+func benchmarkLargeResponse(b *testing.B, opts ...jsonrpc.PrepareOpt) {
 	fixture, err := os.ReadFile("tests/fixtures/btc-block-without-txs.json")
 	if err != nil {
 		b.Fatalf("read fixture: %v", err)
@@ -35,7 +48,7 @@ func BenchmarkExecuteLargeResponse(b *testing.B) {
 	b.ReportAllocs()
 
 	for b.Loop() {
-		prepared := req.Prepare(server.URL)
+		prepared := req.Prepare(server.URL, opts...)
 		res, err := prepared.Execute(client)
 		if err != nil {
 			b.Fatalf("execute: %v", err)
@@ -46,3 +59,125 @@ func BenchmarkExecuteLargeResponse(b *testing.B) {
 		benchmarkResult = res
 	}
 }
+
+func newHTTP2EchoServer() *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+
+		if len(body) > 0 && body[0] == '[' {
+			var batch []struct {
+				ID any `json:"id"`
+			}
+			_ = json.Unmarshal(body, &batch)
+
+			out := make([]jsonrpc.RPCResponse[string], 0, len(batch))
+			for _, entry := range batch {
+				out = append(out, jsonrpc.RPCResponse[string]{JSONRPC: jsonrpc.Version, Result: "pong", ID: entry.ID})
+			}
+			_ = json.NewEncoder(w).Encode(out)
+			return
+		}
+
+		var single struct {
+			ID any `json:"id"`
+		}
+		_ = json.Unmarshal(body, &single)
+		_ = json.NewEncoder(w).Encode(jsonrpc.RPCResponse[string]{JSONRPC: jsonrpc.Version, Result: "pong", ID: single.ID})
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+
+	return server
+}
+
+const benchmarkFanOut = 32
+
+func BenchmarkPipelineHTTP2(b *testing.B) {
+	server := newHTTP2EchoServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		pipeline := jsonrpc.NewPipeline[string](client, benchmarkFanOut)
+		for i := range benchmarkFanOut {
+			req := jsonrpc.NewRequest("ping", []int{i}, jsonrpc.WithRPCid[[]int, string](i))
+			pipeline.Add(req.Prepare(server.URL))
+		}
+
+		for _, res := range pipeline.Execute() {
+			if res.Err != nil {
+				b.Fatalf("execute: %v", res.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkProtocolBatchHTTP2(b *testing.B) {
+	server := newHTTP2EchoServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		batch := make([]any, 0, benchmarkFanOut)
+		for i := range benchmarkFanOut {
+			batch = append(batch, jsonrpc.NewRequest("ping", []int{i}, jsonrpc.WithRPCid[[]int, string](i)))
+		}
+
+		body, err := json.Marshal(batch)
+		if err != nil {
+			b.Fatalf("marshal: %v", err)
+		}
+
+		resp, err := client.Post(server.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			b.Fatalf("post: %v", err)
+		}
+
+		var out []jsonrpc.RPCResponse[string]
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		_ = resp.Body.Close()
+		if err != nil || len(out) != benchmarkFanOut {
+			b.Fatalf("decode: %v", err)
+		}
+	}
+}
+
+func BenchmarkExecuteErrorWithLargeStaleResult(b *testing.B) {
+	fixture, err := os.ReadFile("tests/fixtures/btc-block-without-txs.json")
+	if err != nil {
+		b.Fatalf("read fixture: %v", err)
+	}
+
+	// keep the large result and inject an error member next to it
+	body := append([]byte(`{"error":{"code":-32000,"message":"stale"},`), fixture[1:]...)
+
+	req := jsonrpc.NewRequest(
+		"getblock",
+		[]any{"00000000000000000001246cadf2834cf70fe92404e37c14e071f4b7da61993d", false},
+		jsonrpc.WithRPCid[[]any, types.Block]("bench"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := server.Client()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		prepared := req.Prepare(server.URL)
+		if _, err := prepared.Execute(client); err == nil {
+			b.Fatal("expected rpc error")
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package jsonrpc_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/LiquidCats/jsonrpc/v2/tests/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -166,6 +174,40 @@ func TestExecuteDecodeError(t *testing.T) {
 	require.Contains(t, err.Error(), "decode response")
 }
 
+func TestExecuteRequireJSONContentType(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "rate limited, slow down")
+		}
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("ping", struct{}{}, jsonrpc.WithRPCid[struct{}, string](1))
+
+	res, err := req.Prepare(server.URL+"/json", jsonrpc.WithRequireJSONContentType()).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	_, err = req.Prepare(server.URL, jsonrpc.WithRequireJSONContentType()).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrUnexpectedContentType)
+
+	var typeErr *jsonrpc.ContentTypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.Equal(t, "text/plain", typeErr.ContentType)
+	require.Equal(t, "rate limited, slow down", typeErr.Snippet)
+
+	// lenient by default
+	_, err = req.Prepare(server.URL).Execute(server.Client())
+	require.NotErrorIs(t, err, jsonrpc.ErrUnexpectedContentType)
+}
+
 func TestExecuteCanceledContext(t *testing.T) {
 	t.Parallel()
 
@@ -234,3 +276,1548 @@ func TestPrepareErrorPropagates(t *testing.T) {
 	require.Contains(t, err.Error(), "execute")
 	require.Contains(t, err.Error(), "create http request")
 }
+
+func TestExecuteVersionMismatchWarning(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string](
+		"legacy",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, string]("legacy-1"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"1.0","result":"ok","id":"legacy-1"}`)
+	}))
+	defer server.Close()
+
+	var got []string
+	prepared := req.Prepare(server.URL, jsonrpc.WithVersionMismatchWarning(func(version string) {
+		got = append(got, version)
+	}))
+
+	result, err := prepared.Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *result)
+	require.Equal(t, []string{"1.0"}, got)
+}
+
+func TestExecuteRequireVersion(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			fmt.Fprint(w, `{"result":"ok","id":1}`)
+		case "/legacy":
+			fmt.Fprint(w, `{"jsonrpc":"1.0","result":"ok","id":1}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+		}
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[[]int, string]("getblockcount", nil, jsonrpc.WithRPCid[[]int, string](1))
+
+	// without the option any version is accepted
+	result, err := req.Prepare(server.URL + "/missing").Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *result)
+
+	result, err = req.Prepare(server.URL, jsonrpc.WithRequireVersion("2.0")).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *result)
+
+	for path, got := range map[string]string{"/missing": "", "/legacy": "1.0"} {
+		_, err = req.Prepare(server.URL+path, jsonrpc.WithRequireVersion("2.0")).Execute(server.Client())
+		require.ErrorIs(t, err, jsonrpc.ErrVersionMismatch)
+
+		var versionErr *jsonrpc.VersionError
+		require.ErrorAs(t, err, &versionErr)
+		require.Equal(t, got, versionErr.Got)
+		require.Equal(t, "2.0", versionErr.Want)
+	}
+}
+
+func TestExecuteNotificationOmitsID(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewNotification("eth_unsubscribe", []string{"0x9cef478923ff08bf67fde6c64013158d"})
+	require.Nil(t, req.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+
+		require.NotContains(t, decoded, "id")
+		require.Equal(t, "eth_unsubscribe", decoded["method"])
+		require.Equal(t, jsonrpc.Version, decoded["jsonrpc"])
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := req.Prepare(server.URL).ExecuteNotification(server.Client())
+	require.NoError(t, err)
+}
+
+func TestExecuteNotificationHTTPStatusError(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewNotification("notify", struct{}{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := req.Prepare(server.URL).ExecuteNotification(server.Client())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "http status 502")
+}
+
+func TestPipelineBoundsConcurrencyAndKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	const (
+		total       = 16
+		concurrency = 3
+	)
+
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		var decoded struct {
+			Params []int `json:"params"`
+			ID     any   `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+
+		time.Sleep(10 * time.Millisecond)
+
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%d,"id":%q}`, decoded.Params[0]*2, decoded.ID)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	pipeline := jsonrpc.NewPipeline[int](server.Client(), concurrency)
+	for i := range total {
+		req := jsonrpc.NewRequest[[]int, int](
+			"double",
+			[]int{i},
+			jsonrpc.WithRPCid[[]int, int](fmt.Sprintf("p-%d", i)),
+		)
+		pipeline.Add(req.Prepare(server.URL))
+	}
+
+	results := pipeline.Execute()
+	require.Len(t, results, total)
+
+	for i, res := range results {
+		require.NoError(t, res.Err)
+		require.Equal(t, fmt.Sprintf("p-%d", i), res.ID)
+		require.Equal(t, i*2, *res.Result)
+	}
+
+	require.LessOrEqual(t, peak, concurrency)
+}
+
+func TestExecuteRPCErrorData(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[[]any, string](
+		"eth_call",
+		[]any{},
+		jsonrpc.WithRPCid[[]any, string]("revert"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":3,"message":"execution reverted","data":{"reason":"insufficient balance"}},"id":"revert"}`)
+	}))
+	defer server.Close()
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.Error(t, err)
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, "jsonrpc error: code=3, message=execution reverted", rpcErr.Error())
+	require.JSONEq(t, `{"reason":"insufficient balance"}`, string(rpcErr.Data))
+
+	var data struct {
+		Reason string `json:"reason"`
+	}
+	require.NoError(t, rpcErr.DataAs(&data))
+	require.Equal(t, "insufficient balance", data.Reason)
+}
+
+func TestRPCErrorDataAsWithoutData(t *testing.T) {
+	t.Parallel()
+
+	rpcErr := &jsonrpc.RPCError{Code: -32000, Message: "boom"}
+
+	var data any
+	require.Error(t, rpcErr.DataAs(&data))
+}
+
+func TestStandardErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		code int
+		want int
+	}{
+		{name: "parse error", code: jsonrpc.CodeParseError, want: -32700},
+		{name: "invalid request", code: jsonrpc.CodeInvalidRequest, want: -32600},
+		{name: "method not found", code: jsonrpc.CodeMethodNotFound, want: -32601},
+		{name: "invalid params", code: jsonrpc.CodeInvalidParams, want: -32602},
+		{name: "internal error", code: jsonrpc.CodeInternalError, want: -32603},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.code)
+			require.True(t, (&jsonrpc.RPCError{Code: tc.code}).IsStandard())
+		})
+	}
+
+	require.False(t, (&jsonrpc.RPCError{Code: -32000}).IsStandard())
+	require.False(t, (&jsonrpc.RPCError{Code: 3}).IsStandard())
+}
+
+func TestIsErrorCodeHelpers(t *testing.T) {
+	t.Parallel()
+
+	wrapped := func(code int) error {
+		return fmt.Errorf("probe: %w", &jsonrpc.RPCError{Code: code, Message: "server message"})
+	}
+
+	require.True(t, jsonrpc.IsMethodNotFound(wrapped(jsonrpc.CodeMethodNotFound)))
+	require.True(t, jsonrpc.IsInvalidParams(wrapped(jsonrpc.CodeInvalidParams)))
+	require.True(t, jsonrpc.IsParseError(wrapped(jsonrpc.CodeParseError)))
+
+	require.False(t, jsonrpc.IsMethodNotFound(wrapped(jsonrpc.CodeInvalidParams)))
+	require.False(t, jsonrpc.IsMethodNotFound(wrapped(-32000)))
+	require.False(t, jsonrpc.IsMethodNotFound(errors.New("method not found")))
+	require.False(t, jsonrpc.IsMethodNotFound(nil))
+}
+
+func captureRequestBody(t *testing.T, bodies chan<- map[string]json.RawMessage) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded map[string]json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+		bodies <- decoded
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"","id":1}`)
+	}))
+}
+
+func TestPrepareRPCVersion(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	for _, mode := range []jsonrpc.ParamsMode{jsonrpc.ParamsAsIs, jsonrpc.EmptyArrayParams} {
+		_, err := jsonrpc.NewRequest[[]any, string](
+			"getinfo",
+			nil,
+			jsonrpc.WithRPCVersion[[]any, string]("1.0"),
+			jsonrpc.WithParamsMode[[]any, string](mode),
+		).Prepare(server.URL).Execute(server.Client())
+		require.NoError(t, err)
+		require.JSONEq(t, `"1.0"`, string((<-bodies)["jsonrpc"]))
+
+		// an empty version leaves the member out for strict 1.0 servers
+		_, err = jsonrpc.NewRequest[[]any, string](
+			"getinfo",
+			nil,
+			jsonrpc.WithRPCVersion[[]any, string](""),
+			jsonrpc.WithParamsMode[[]any, string](mode),
+		).Prepare(server.URL).Execute(server.Client())
+		require.NoError(t, err)
+		require.NotContains(t, <-bodies, "jsonrpc")
+	}
+}
+
+func TestPrepareParamsModes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		mode    jsonrpc.ParamsMode
+		params  any
+		present bool
+		want    string
+	}{
+		{name: "as is nil", mode: jsonrpc.ParamsAsIs, params: nil},
+		{name: "as is empty slice", mode: jsonrpc.ParamsAsIs, params: []any{}, present: true, want: `[]`},
+		{name: "as is empty map", mode: jsonrpc.ParamsAsIs, params: map[string]any{}, present: true, want: `{}`},
+		{name: "omit empty struct", mode: jsonrpc.OmitParams, params: struct{}{}},
+		{name: "omit nil", mode: jsonrpc.OmitParams, params: nil},
+		{name: "array from nil", mode: jsonrpc.EmptyArrayParams, params: nil, present: true, want: `[]`},
+		{name: "array from empty map", mode: jsonrpc.EmptyArrayParams, params: map[string]any{}, present: true, want: `[]`},
+		{name: "object from nil", mode: jsonrpc.EmptyObjectParams, params: nil, present: true, want: `{}`},
+		{name: "object from empty slice", mode: jsonrpc.EmptyObjectParams, params: []any{}, present: true, want: `{}`},
+		{name: "non-empty untouched", mode: jsonrpc.EmptyObjectParams, params: []any{1}, present: true, want: `[1]`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bodies := make(chan map[string]json.RawMessage, 1)
+			server := captureRequestBody(t, bodies)
+			defer server.Close()
+
+			req := jsonrpc.NewRequest[any, string](
+				"params",
+				tc.params,
+				jsonrpc.WithRPCid[any, string](1),
+				jsonrpc.WithParamsMode[any, string](tc.mode),
+			)
+
+			_, err := req.Prepare(server.URL).Execute(server.Client())
+			require.NoError(t, err)
+
+			body := <-bodies
+			params, ok := body["params"]
+			require.Equal(t, tc.present, ok)
+			if tc.present {
+				require.JSONEq(t, tc.want, string(params))
+			}
+			require.JSONEq(t, `"params"`, string(body["method"]))
+			require.JSONEq(t, `1`, string(body["id"]))
+		})
+	}
+}
+
+func TestPrepareExplicitEmptySlice(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	// omitempty drops a typed empty slice unless the mode asks for it
+	_, err := jsonrpc.NewRequest[[]string, string]("eth_accounts", []string{}).
+		Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.NotContains(t, <-bodies, "params")
+
+	_, err = jsonrpc.NewRequest[[]string, string](
+		"eth_accounts",
+		[]string{},
+		jsonrpc.WithParamsMode[[]string, string](jsonrpc.EmptyArrayParams),
+	).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	body := <-bodies
+	require.Contains(t, body, "params")
+	require.JSONEq(t, `[]`, string(body["params"]))
+}
+
+func TestRPCErrorIsMatchesByCode(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string](
+		"unknown",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, string]("is-1"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"the method unknown does not exist"},"id":"is-1"}`)
+	}))
+	defer server.Close()
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.Error(t, err)
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+	require.NotErrorIs(t, err, jsonrpc.ErrInvalidParams)
+	require.True(t, jsonrpc.IsMethodNotFound(err))
+	require.False(t, jsonrpc.IsInvalidParams(err))
+	require.False(t, jsonrpc.IsParseError(err))
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, "the method unknown does not exist", rpcErr.Message)
+
+	custom := &jsonrpc.RPCError{Code: -32001, Message: "custom"}
+	for _, sentinel := range []error{
+		jsonrpc.ErrParseError,
+		jsonrpc.ErrInvalidRequest,
+		jsonrpc.ErrMethodNotFound,
+		jsonrpc.ErrInvalidParams,
+		jsonrpc.ErrInternalError,
+	} {
+		require.NotErrorIs(t, custom, sentinel)
+	}
+}
+
+func TestExecuteResponsePreprocessor(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string](
+		"quirky",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, string]("pre-1"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ")]}'\n{\"jsonrpc\":\"2.0\",\"result\":\"fixed\",\"id\":\"pre-1\"}")
+	}))
+	defer server.Close()
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decode response")
+
+	prepared := req.Prepare(server.URL, jsonrpc.WithResponsePreprocessor(func(body []byte) ([]byte, error) {
+		return bytes.TrimPrefix(body, []byte(")]}'\n")), nil
+	}))
+
+	result, err := prepared.Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "fixed", *result)
+}
+
+func TestExecuteResponsePreprocessorError(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string]("quirky", struct{}{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `garbage`)
+	}))
+	defer server.Close()
+
+	prepared := req.Prepare(server.URL, jsonrpc.WithResponsePreprocessor(func([]byte) ([]byte, error) {
+		return nil, errors.New("unfixable")
+	}))
+
+	_, err := prepared.Execute(server.Client())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "preprocess response")
+	require.Contains(t, err.Error(), "unfixable")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestExecuteUsesSuppliedClientTransport(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string](
+		"sentinel",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, string]("s-1"),
+	)
+
+	var invoked bool
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			invoked = true
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","result":"via sentinel","id":"s-1"}`)),
+				Request:    r,
+			}, nil
+		}),
+	}
+
+	result, err := req.Prepare("http://sentinel.invalid").Execute(client)
+	require.NoError(t, err)
+	require.True(t, invoked, "custom transport should be used")
+	require.Equal(t, "via sentinel", *result)
+}
+
+func TestExecuteRPCErrorSkipsResultDecoding(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, int](
+		"stale",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, int]("stale-1"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// result does not match the int result type; it must not be decoded
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":{"not":"an int"},"error":{"code":-32000,"message":"stale"},"id":"stale-1"}`)
+	}))
+	defer server.Close()
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+
+	require.ErrorIs(t, err, jsonrpc.ErrAmbiguousResponse)
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, "stale", rpcErr.Message)
+}
+
+func TestExecuteErrorWithNullResultIsNotAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, int](
+		"failing",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, int]("null-1"),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":null,"error":{"code":-32000,"message":"failed"},"id":"null-1"}`)
+	}))
+	defer server.Close()
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.NotErrorIs(t, err, jsonrpc.ErrAmbiguousResponse)
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, "failed", rpcErr.Message)
+}
+
+func TestExecuteContextCanceledMidFlight(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string](
+		"slow",
+		struct{}{},
+		jsonrpc.WithRPCid[struct{}, string]("slow-1"),
+	)
+
+	arrived := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only notices the client going away once the body is consumed
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(arrived)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-arrived
+		cancel()
+	}()
+
+	started := time.Now()
+	_, err := req.Prepare(server.URL, jsonrpc.WithContext(ctx)).Execute(server.Client())
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(started), 5*time.Second)
+}
+
+// not parallel: package-wide defaults are shared state
+func TestSetDefaultsPrecedence(t *testing.T) {
+	jsonrpc.SetDefaults(
+		jsonrpc.WithHeader("User-Agent", "defaults/1.0"),
+		jsonrpc.WithHeader("X-Tenant", "default"),
+	)
+	defer jsonrpc.SetDefaults()
+
+	headers := make(chan http.Header, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"","id":1}`)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("defaults", struct{}{})
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	got := <-headers
+	require.Equal(t, "defaults/1.0", got.Get("User-Agent"))
+	require.Equal(t, "default", got.Get("X-Tenant"))
+
+	_, err = req.Prepare(server.URL, jsonrpc.WithHeader("X-Tenant", "override")).Execute(server.Client())
+	require.NoError(t, err)
+
+	got = <-headers
+	require.Equal(t, "defaults/1.0", got.Get("User-Agent"))
+	require.Equal(t, "override", got.Get("X-Tenant"))
+}
+
+// not parallel: package-wide defaults are shared state
+func TestSetDefaultsConcurrentUse(t *testing.T) {
+	defer jsonrpc.SetDefaults()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"","id":1}`)
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			jsonrpc.SetDefaults(jsonrpc.WithHeader("X-Worker", strconv.Itoa(i)))
+		})
+		wg.Go(func() {
+			req := jsonrpc.NewRequest[struct{}, string]("defaults", struct{}{})
+			_, err := req.Prepare(server.URL).Execute(server.Client())
+			require.NoError(t, err)
+		})
+	}
+	wg.Wait()
+}
+
+func TestExecuteOptionsDoNotLeakIntoDefaultClient(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"slow","id":1}`)
+	}))
+	defer server.Close()
+
+	withTimeout := func(d time.Duration) jsonrpc.ExecuteOpt {
+		return func(cli *http.Client) {
+			cli.Timeout = d
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		fastErr error
+		slowErr error
+	)
+
+	wg.Go(func() {
+		req := jsonrpc.NewRequest[struct{}, string]("fast", struct{}{})
+		_, fastErr = req.Prepare(server.URL).Execute(nil, withTimeout(50*time.Millisecond))
+	})
+	wg.Go(func() {
+		req := jsonrpc.NewRequest[struct{}, string]("slow", struct{}{})
+		_, slowErr = req.Prepare(server.URL).Execute(nil, withTimeout(5*time.Second))
+	})
+	wg.Wait()
+
+	require.Error(t, fastErr)
+	require.NoError(t, slowErr)
+
+	// a later call without options must not inherit any timeout
+	req := jsonrpc.NewRequest[struct{}, string]("plain", struct{}{})
+	result, err := req.Prepare(server.URL).Execute(nil)
+	require.NoError(t, err)
+	require.Equal(t, "slow", *result)
+}
+
+func newSlowServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"late","id":1}`)
+	}))
+}
+
+func TestExecuteWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := newSlowServer(2 * time.Second)
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("slow", struct{}{})
+
+	started := time.Now()
+	_, err := req.Prepare(server.URL, jsonrpc.WithTimeout(100*time.Millisecond)).Execute(server.Client())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(started), time.Second)
+}
+
+// newDribblingServer sends the headers and the start of the body at once,
+// then the rest of a large result a byte at a time every interval.
+func newDribblingServer(interval time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		flusher := w.(http.Flusher)
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"`)
+		flusher.Flush()
+
+		for range 1_000 {
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+
+			fmt.Fprint(w, "0")
+			flusher.Flush()
+		}
+
+		fmt.Fprint(w, `"}`)
+	}))
+}
+
+func TestExecuteContextAbortsBodyRead(t *testing.T) {
+	t.Parallel()
+
+	server := newDribblingServer(20 * time.Millisecond)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithContext(ctx)).
+		Execute(server.Client())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "read response")
+	require.Less(t, time.Since(started), time.Second)
+}
+
+func TestExecuteWithTimeoutTakesEarlierDeadline(t *testing.T) {
+	t.Parallel()
+
+	server := newSlowServer(2 * time.Second)
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("slow", struct{}{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// WithTimeout is applied before WithContext and is longer; the context deadline wins
+	started := time.Now()
+	_, err := req.Prepare(server.URL, jsonrpc.WithTimeout(5*time.Second), jsonrpc.WithContext(ctx)).Execute(server.Client())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(started), time.Second)
+
+	// a shorter WithTimeout wins over a longer context deadline
+	longCtx, longCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer longCancel()
+
+	started = time.Now()
+	_, err = req.Prepare(server.URL, jsonrpc.WithContext(longCtx), jsonrpc.WithTimeout(100*time.Millisecond)).Execute(server.Client())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(started), time.Second)
+}
+
+func TestExecuteWithTimeoutSucceedsWithinDeadline(t *testing.T) {
+	t.Parallel()
+
+	server := newSlowServer(10 * time.Millisecond)
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("slow", struct{}{})
+
+	result, err := req.Prepare(server.URL, jsonrpc.WithTimeout(2*time.Second)).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "late", *result)
+}
+
+func TestPrepareAuthHeaders(t *testing.T) {
+	t.Parallel()
+
+	expected, err := http.NewRequest(http.MethodPost, "http://example.invalid", nil)
+	require.NoError(t, err)
+	expected.SetBasicAuth("rpcuser", "p@ss:word")
+
+	cases := []struct {
+		name string
+		opt  jsonrpc.PrepareOpt
+		want string
+	}{
+		{name: "basic", opt: jsonrpc.WithBasicAuth("rpcuser", "p@ss:word"), want: expected.Header.Get("Authorization")},
+		{name: "bearer", opt: jsonrpc.WithBearerToken("secret-token"), want: "Bearer secret-token"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			headers := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers <- r.Header.Get("Authorization")
+				fmt.Fprint(w, `{"jsonrpc":"2.0","result":"","id":1}`)
+			}))
+			defer server.Close()
+
+			req := jsonrpc.NewRequest[struct{}, string]("auth", struct{}{})
+			_, err := req.Prepare(server.URL, tc.opt).Execute(server.Client())
+			require.NoError(t, err)
+			require.Equal(t, tc.want, <-headers)
+		})
+	}
+}
+
+func TestNewRequestIDsAreDistinct(t *testing.T) {
+	t.Parallel()
+
+	const total = 10_000
+
+	seen := make(map[any]struct{}, total)
+	for range total {
+		req := jsonrpc.NewRequest[struct{}, string]("id", struct{}{})
+		_, dup := seen[req.ID]
+		require.False(t, dup, "duplicate id %v", req.ID)
+		seen[req.ID] = struct{}{}
+	}
+}
+
+func TestSameID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b any
+		want bool
+	}{
+		{a: int64(123), b: float64(123), want: true},
+		{a: int64(123), b: "123", want: true},
+		{a: float64(123), b: "123", want: true},
+		{a: 123, b: json.Number("123.0"), want: true},
+		{a: uint64(1 << 60), b: float64(1 << 60), want: true},
+		{a: int64(1 << 60), b: json.Number("1152921504606846976"), want: true},
+		{a: "abc", b: "abc", want: true},
+		{a: int64(123), b: float64(123.5), want: false},
+		{a: int64(123), b: "0123", want: false},
+		{a: "abc", b: "ABC", want: false},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, jsonrpc.SameID(tc.a, tc.b), "%#v vs %#v", tc.a, tc.b)
+		require.Equal(t, tc.want, jsonrpc.SameID(tc.b, tc.a), "%#v vs %#v", tc.b, tc.a)
+	}
+}
+
+func TestNewRequestWithIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	gen := jsonrpc.NewCounterIDGenerator(41)
+
+	first := jsonrpc.NewRequest("a", struct{}{}, jsonrpc.WithIDGenerator[struct{}, string](gen))
+	second := jsonrpc.NewRequest("b", struct{}{}, jsonrpc.WithIDGenerator[struct{}, string](gen))
+
+	require.Equal(t, "42", first.ID)
+	require.Equal(t, "43", second.ID)
+
+	var wg sync.WaitGroup
+	ids := make([]any, 100)
+	for i := range ids {
+		wg.Go(func() {
+			ids[i] = jsonrpc.NewRequest("c", struct{}{}, jsonrpc.WithIDGenerator[struct{}, string](gen)).ID
+		})
+	}
+	wg.Wait()
+
+	require.Len(t, ids, 100)
+	unique := make(map[any]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	require.Len(t, unique, 100)
+}
+
+type countingCodec struct {
+	jsonrpc.Codec
+	encoders atomic.Int32
+	decodes  atomic.Int32
+}
+
+func (c *countingCodec) NewEncoder(w io.Writer) jsonrpc.Encoder {
+	c.encoders.Add(1)
+	return c.Codec.NewEncoder(w)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.decodes.Add(1)
+	return c.Codec.Unmarshal(data, v)
+}
+
+func TestExecuteWithCodec(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies <- string(body)
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":{"status":"ok","items":[1,2,3]},"id":"codec"}`)
+	}))
+	defer server.Close()
+
+	type result struct {
+		Status string `json:"status"`
+		Items  []int  `json:"items"`
+	}
+
+	type params struct {
+		Value int    `json:"value"`
+		Name  string `json:"name"`
+	}
+
+	req := jsonrpc.NewRequest[params, result](
+		"codec",
+		params{Value: 1, Name: "x"},
+		jsonrpc.WithRPCid[params, result]("codec"),
+	)
+
+	codec := &countingCodec{Codec: jsonrpc.StdCodec}
+
+	got, err := req.Prepare(server.URL, jsonrpc.WithCodec(codec)).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, result{Status: "ok", Items: []int{1, 2, 3}}, *got)
+	require.Equal(t, int32(1), codec.encoders.Load())
+	require.Positive(t, codec.decodes.Load())
+
+	_, err = req.Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	// the wire format does not depend on the codec
+	require.Equal(t, <-bodies, <-bodies)
+}
+
+func TestExecuteMaxResponseBytes(t *testing.T) {
+	t.Parallel()
+
+	const limit = 1 << 10
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sized") != "" {
+			w.Header().Set("Content-Length", strconv.Itoa(4*limit))
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"`)
+		for range 4 {
+			fmt.Fprint(w, strings.Repeat("a", limit))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("huge", struct{}{})
+
+	for _, url := range []string{server.URL, server.URL + "?sized=1"} {
+		_, err := req.Prepare(url, jsonrpc.WithMaxResponseBytes(limit)).Execute(server.Client())
+		require.ErrorIs(t, err, jsonrpc.ErrResponseTooLarge)
+	}
+}
+
+func TestExecuteMaxResponseBytesWithinLimit(t *testing.T) {
+	t.Parallel()
+
+	body := `{"jsonrpc":"2.0","result":"fits","id":1}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("fits", struct{}{})
+
+	result, err := req.Prepare(server.URL, jsonrpc.WithMaxResponseBytes(int64(len(body)))).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "fits", *result)
+}
+
+func TestExecuteResponseBufferSize(t *testing.T) {
+	t.Parallel()
+
+	const size = 64 << 10
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// flushed in parts, so the body is chunked and its size unknown
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"`)
+		for range 4 {
+			fmt.Fprint(w, strings.Repeat("a", size/4))
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, `"}`)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{})
+
+	for _, n := range []int{16, 2 * size} {
+		res, err := req.Prepare(server.URL, jsonrpc.WithResponseBufferSize(n)).Execute(server.Client())
+		require.NoError(t, err)
+		require.Len(t, *res, size)
+	}
+
+	// the response limit still applies to a larger buffer
+	_, err := req.Prepare(
+		server.URL,
+		jsonrpc.WithResponseBufferSize(2*size),
+		jsonrpc.WithMaxResponseBytes(size/2),
+	).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrResponseTooLarge)
+}
+
+func TestExecuteOverstatedContentLength(t *testing.T) {
+	t.Parallel()
+
+	body := `{"jsonrpc":"2.0","id":1,"result":"ok"}`
+
+	// a length no buffer could be allocated for up front, with no limit to
+	// reject it first
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: 1 << 50,
+			Request:       r,
+		}, nil
+	})}
+
+	res, err := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{}).
+		Prepare("http://node.invalid", jsonrpc.WithMaxResponseBytes(0)).
+		Execute(client)
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+}
+
+func TestExecuteFull(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "99")
+
+		switch r.URL.Path {
+		case "/error":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"boom"},"id":1}`)
+		case "/throttled":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+		}
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("full", struct{}{})
+
+	full, err := req.Prepare(server.URL).ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, full.StatusCode)
+	require.Equal(t, "99", full.Header.Get("X-RateLimit-Remaining"))
+	require.Nil(t, full.Error)
+	require.Equal(t, "ok", *full.Result)
+	require.Equal(t, float64(1), full.ID)
+
+	full, err = req.Prepare(server.URL + "/error").ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.Nil(t, full.Result)
+	require.Equal(t, -32000, full.Error.Code)
+	require.Equal(t, float64(1), full.ID)
+	require.Equal(t, "99", full.Header.Get("X-RateLimit-Remaining"))
+
+	full, err = req.Prepare(server.URL + "/throttled").ExecuteFull(server.Client())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "http status 429")
+	require.NotNil(t, full)
+	require.Equal(t, http.StatusTooManyRequests, full.StatusCode)
+	require.Equal(t, "1", full.Header.Get("Retry-After"))
+}
+
+func TestExecuteFullExposesEnvelope(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":{"shard":3,"seq":"a1"}}`)
+	}))
+	defer server.Close()
+
+	full, err := jsonrpc.NewRequest[struct{}, string]("echo", struct{}{}).
+		Prepare(server.URL).
+		ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"shard": float64(3), "seq": "a1"}, full.ID)
+	require.Equal(t, "2.0", full.JSONRPC)
+	require.Equal(t, "ok", *full.Result)
+	require.Nil(t, full.Error)
+}
+
+func TestExecuteFullNullResult(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/null":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":null,"id":1}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":{},"id":1}`)
+		}
+	}))
+	defer server.Close()
+
+	type tx struct {
+		Hash string `json:"hash"`
+	}
+
+	req := jsonrpc.NewRequest[[]string, tx]("eth_getTransactionByHash", []string{"0xabc"})
+
+	full, err := req.Prepare(server.URL + "/null").ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.True(t, full.NullResult)
+	require.Equal(t, tx{}, *full.Result)
+
+	full, err = req.Prepare(server.URL).ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.False(t, full.NullResult)
+	require.Equal(t, tx{}, *full.Result)
+
+	ptrFull, err := jsonrpc.NewRequest[[]string, *tx]("eth_getTransactionByHash", []string{"0xabc"}).
+		Prepare(server.URL + "/null").
+		ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.True(t, ptrFull.NullResult)
+	require.Nil(t, *ptrFull.Result)
+}
+
+func TestExecuteFullDuration(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	full, err := jsonrpc.NewRequest[struct{}, string]("slow", struct{}{}).
+		Prepare(server.URL).
+		ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, full.NetworkDuration, 20*time.Millisecond)
+	require.Positive(t, full.DecodeDuration)
+	require.Equal(t, full.Duration, full.NetworkDuration+full.DecodeDuration)
+}
+
+func TestPreparedRequestIsReusable(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 8)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{883189}).Prepare(server.URL)
+
+	_, err := prepared.Execute(server.Client())
+	require.NoError(t, err)
+
+	_, err = prepared.Execute(server.Client())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			_, err := prepared.Execute(server.Client())
+			require.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	close(bodies)
+	require.Len(t, bodies, 6)
+
+	for body := range bodies {
+		require.Equal(t, `"getblockhash"`, string(body["method"]))
+		require.Equal(t, `[883189]`, string(body["params"]))
+	}
+}
+
+func TestPrepareTypedNilParamsAreOmitted(t *testing.T) {
+	t.Parallel()
+
+	type named struct {
+		Height int `json:"height"`
+	}
+
+	bodies := make(chan map[string]json.RawMessage, 4)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest[[]int, string]("positional", nil).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	_, err = jsonrpc.NewRequest[*named, string]("named", nil).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	_, err = jsonrpc.NewRequest[map[string]any, string]("map", nil).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	_, err = jsonrpc.NewRequest[*named, string]("named", &named{Height: 1}).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+
+	close(bodies)
+
+	var sent []map[string]json.RawMessage
+	for body := range bodies {
+		sent = append(sent, body)
+	}
+
+	require.Len(t, sent, 4)
+	for _, body := range sent[:3] {
+		require.NotContains(t, body, "params")
+	}
+	require.JSONEq(t, `{"height":1}`, string(sent[3]["params"]))
+}
+
+func TestPrepareUserAgent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		opts []jsonrpc.PrepareOpt
+		want string
+	}{
+		{name: "default", want: jsonrpc.DefaultUserAgent},
+		{name: "option", opts: []jsonrpc.PrepareOpt{jsonrpc.WithUserAgent("indexer/1.4")}, want: "indexer/1.4"},
+		{name: "header", opts: []jsonrpc.PrepareOpt{jsonrpc.WithHeader("User-Agent", "by-hand/0.1")}, want: "by-hand/0.1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			agents := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				agents <- r.UserAgent()
+				fmt.Fprint(w, `{"jsonrpc":"2.0","result":"","id":1}`)
+			}))
+			defer server.Close()
+
+			req := jsonrpc.NewRequest[struct{}, string]("agent", struct{}{})
+			_, err := req.Prepare(server.URL, tc.opts...).Execute(server.Client())
+			require.NoError(t, err)
+			require.Equal(t, tc.want, <-agents)
+		})
+	}
+}
+
+func TestExecuteHTTPErrorQuotesBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid API key in %s\n%s", r.URL.Path, strings.Repeat("x", 1024))
+	}))
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest[[]int, int]("getblockcount", nil).
+		Prepare(server.URL + "/v2/secret-key").
+		Execute(server.Client())
+	require.ErrorContains(t, err, `http status 400: "invalid API key in [redacted]`)
+	require.NotContains(t, err.Error(), "secret-key")
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	require.Len(t, httpErr.Snippet, 256-len("/v2/secret-key")+len("[redacted]"))
+}
+
+func TestExecuteErrorNamesMethodAndEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down/secret-key" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-5,"message":"Block not found"},"id":1}`)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest("getblock", []string{"0000abc"}, jsonrpc.WithRPCid[[]string, string](1))
+
+	_, err := req.Prepare(server.URL + "/down/secret-key").Execute(server.Client())
+	require.ErrorContains(t, err, "execute getblock on "+server.URL+": http status 500")
+	require.NotContains(t, err.Error(), "secret-key")
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+
+	_, err = req.Prepare(server.URL).Execute(server.Client())
+	require.ErrorContains(t, err, "execute getblock on "+server.URL)
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -5, rpcErr.Code)
+}
+
+func TestExecuteRawMessageResultIsVerbatim(t *testing.T) {
+	t.Parallel()
+
+	const result = `{ "hash": "0000abc",  "tx": [1, 2.50, "é"], "nested": {"a": null} }`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%s,"id":1}`, result)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest("getblock", []string{"0000abc"}, jsonrpc.WithRPCid[[]string, json.RawMessage](1))
+
+	raw, err := req.Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, result, string(*raw))
+
+	std, err := req.Prepare(server.URL, jsonrpc.WithCodec(jsonrpc.StdCodec)).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, result, string(*std))
+
+	var called json.RawMessage
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()))
+	require.NoError(t, client.Call(context.Background(), "getblock", []string{"0000abc"}, &called))
+	require.Equal(t, result, string(called))
+}
+
+func TestCall(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	res, err := jsonrpc.Call[[]int, string](
+		context.Background(),
+		server.Client(),
+		server.URL,
+		"getblockhash",
+		[]int{883189},
+		jsonrpc.WithHeader("X-Test", "1"),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	body := <-bodies
+	require.Equal(t, `"getblockhash"`, string(body["method"]))
+	require.Equal(t, `[883189]`, string(body["params"]))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = jsonrpc.Call[[]int, string](ctx, server.Client(), server.URL, "getblockhash", []int{1})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPrepareWithQueryParam(t *testing.T) {
+	t.Parallel()
+
+	queries := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.RawQuery
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{})
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithQueryParam("tenant", "a b&c")).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "tenant=a+b%26c", <-queries)
+
+	_, err = req.Prepare(
+		server.URL+"/v2?z=1&apikey=k%2F1",
+		jsonrpc.WithQueryParam("tenant", "acme"),
+		jsonrpc.WithQueryParam("region", "eu"),
+	).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "z=1&apikey=k%2F1&tenant=acme&region=eu", <-queries)
+}
+
+func TestPrepareAcceptHeaderOverride(t *testing.T) {
+	t.Parallel()
+
+	accepts := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts <- r.Header.Get("Accept")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{})
+
+	_, err := req.Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.Empty(t, <-accepts)
+
+	_, err = req.Prepare(server.URL, jsonrpc.WithHeader("Accept", "application/json-rpc")).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "application/json-rpc", <-accepts)
+}
+
+func TestExecuteTransportErrorClassification(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{})
+
+	t.Run("connection refused", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.NotFoundHandler())
+		url := server.URL
+		server.Close()
+
+		_, err := req.Prepare(url).Execute(nil)
+		require.ErrorIs(t, err, jsonrpc.ErrTransport)
+		require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+		var transportErr *jsonrpc.TransportError
+		require.ErrorAs(t, err, &transportErr)
+		require.False(t, transportErr.Timeout())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		_, err := req.Prepare(server.URL, jsonrpc.WithTimeout(20*time.Millisecond)).Execute(server.Client())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var transportErr *jsonrpc.TransportError
+		require.ErrorAs(t, err, &transportErr)
+		require.True(t, transportErr.Timeout())
+	})
+
+	t.Run("application error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"boom"},"id":1}`)
+		}))
+		defer server.Close()
+
+		_, err := req.Prepare(server.URL).Execute(server.Client())
+		require.Error(t, err)
+		require.NotErrorIs(t, err, jsonrpc.ErrTransport)
+	})
+
+	t.Run("canceled by caller", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := req.Prepare("http://node.invalid", jsonrpc.WithContext(ctx)).Execute(nil)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, jsonrpc.ErrTransport)
+	})
+}
+
+func TestExecuteWithValidate(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := ""
+		if r.URL.Path == "/ok" {
+			hash = "0000abc"
+		}
+
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":{"hash":%q,"height":883189},"id":1}`, hash)
+	}))
+	defer server.Close()
+
+	errEmptyHash := errors.New("block has an empty hash")
+
+	req := jsonrpc.NewRequest(
+		"getblock",
+		[]string{"0000abc"},
+		jsonrpc.WithValidate[[]string](func(b *types.Block) error {
+			if b.Hash == "" {
+				return errEmptyHash
+			}
+
+			return nil
+		}),
+	)
+
+	block, err := req.Prepare(server.URL + "/ok").Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "0000abc", block.Hash)
+
+	_, err = req.Prepare(server.URL).Execute(server.Client())
+	require.ErrorIs(t, err, errEmptyHash)
+	require.ErrorContains(t, err, "validate result")
+
+	full, err := req.Prepare(server.URL).ExecuteFull(server.Client())
+	require.ErrorIs(t, err, errEmptyHash)
+	require.Equal(t, 883189, full.Result.Height)
+}
+
+func TestExecuteWithStrictDecode(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/drift" {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":{"hash":"0000abc","height":1,"weight":4000},"id":1}`)
+			return
+		}
+
+		// unknown envelope members are not the result's concern
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":{"hash":"0000abc","height":1},"id":1,"node":"a"}`)
+	}))
+	defer server.Close()
+
+	type header struct {
+		Hash   string `json:"hash"`
+		Height int    `json:"height"`
+	}
+
+	req := jsonrpc.NewRequest[[]string, header]("getblockheader", []string{"0000abc"})
+
+	for _, codec := range []jsonrpc.Codec{jsonrpc.SonicCodec, jsonrpc.StdCodec} {
+		res, err := req.Prepare(server.URL+"/drift", jsonrpc.WithCodec(codec)).Execute(server.Client())
+		require.NoError(t, err)
+		require.Equal(t, "0000abc", res.Hash)
+
+		_, err = req.Prepare(server.URL+"/drift", jsonrpc.WithCodec(codec), jsonrpc.WithStrictDecode()).Execute(server.Client())
+		require.ErrorContains(t, err, "decode response result")
+		require.ErrorContains(t, err, "weight")
+
+		res, err = req.Prepare(server.URL, jsonrpc.WithCodec(codec), jsonrpc.WithStrictDecode()).Execute(server.Client())
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Height)
+	}
+}
@@ -0,0 +1,39 @@
+package jsonrpc
+
+import (
+	"context"
+	"slices"
+)
+
+// Method binds a method name to its params and result types, so a registry
+// of package-level descriptors replaces method strings at call sites:
+//
+//	var GetBlockHash = jsonrpc.NewMethod[[]int, string]("getblockhash")
+//
+//	hash, err := GetBlockHash.Call(ctx, client, []int{height})
+//
+// The zero value is not usable; create descriptors with NewMethod.
+type Method[Params any, Resp any] struct {
+	name string
+	opts []PrepareOpt
+}
+
+// NewMethod describes method. opts apply to every call of it, after the
+// client's defaults and before per-call options.
+func NewMethod[Params any, Resp any](name string, opts ...PrepareOpt) Method[Params, Resp] {
+	return Method[Params, Resp]{name: name, opts: opts}
+}
+
+func (m Method[Params, Resp]) Name() string {
+	return m.name
+}
+
+// Request creates a request of the method, e.g. to add it to a batch.
+func (m Method[Params, Resp]) Request(params Params, opts ...RPCOpt[Params, Resp]) *rpcRequest[Params, Resp] {
+	return NewRequest(m.name, params, opts...)
+}
+
+// Call sends the method with params through c and returns its result.
+func (m Method[Params, Resp]) Call(ctx context.Context, c *Client, params Params, opts ...PrepareOpt) (*Resp, error) {
+	return m.Request(params).Send(ctx, c, slices.Concat(m.opts, opts)...)
+}
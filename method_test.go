@@ -0,0 +1,53 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	getBlockCount = jsonrpc.NewMethod[[]int, int]("getblockcount")
+	getBlockHash  = jsonrpc.NewMethod[[]int, string]("getblockhash", jsonrpc.WithHeader("X-Method", "getblockhash"))
+)
+
+func TestMethodCall(t *testing.T) {
+	t.Parallel()
+
+	mock := jsonrpc.NewMockTransport()
+	mock.Respond("getblockcount", 883189)
+	mock.Handle("getblockhash", func(_ context.Context, params json.RawMessage) (any, error) {
+		var height []int
+		if err := json.Unmarshal(params, &height); err != nil {
+			return nil, err
+		}
+
+		return map[int]string{883189: "0xabc"}[height[0]], nil
+	})
+
+	client := jsonrpc.NewClient("http://node.invalid", jsonrpc.WithTransport(mock))
+
+	height, err := getBlockCount.Call(context.Background(), client, nil)
+	require.NoError(t, err)
+	require.Equal(t, 883189, *height)
+
+	hash, err := getBlockHash.Call(context.Background(), client, []int{*height})
+	require.NoError(t, err)
+	require.Equal(t, "0xabc", *hash)
+
+	calls := mock.Calls(getBlockHash.Name())
+	require.Len(t, calls, 1)
+	require.JSONEq(t, `[883189]`, string(calls[0].Params))
+	require.Equal(t, "getblockhash", calls[0].Header.Get("X-Method"))
+
+	results, err := jsonrpc.NewBatch(
+		getBlockHash.Request([]int{883189}),
+		getBlockHash.Request([]int{1}),
+	).Prepare("http://node.invalid").Execute(&http.Client{Transport: mock})
+	require.NoError(t, err)
+	require.Equal(t, "0xabc", results[0].Result)
+}
@@ -0,0 +1,45 @@
+package jsonrpc
+
+import (
+	"time"
+)
+
+// Metrics receives per-call measurements, e.g. for a Prometheus adapter.
+// ObserveDuration gets the call's error, a JSON-RPC error included, or nil.
+type Metrics interface {
+	IncInFlight(method string)
+	DecInFlight(method string)
+	ObserveDuration(method string, d time.Duration, err error)
+}
+
+// NopMetrics discards everything; embed it to implement only part of
+// Metrics.
+type NopMetrics struct{}
+
+func (NopMetrics) IncInFlight(string) {}
+
+func (NopMetrics) DecInFlight(string) {}
+
+func (NopMetrics) ObserveDuration(string, time.Duration, error) {}
+
+func WithMetrics(m Metrics) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.metrics = m
+	}
+}
+
+func (rpc *praparedRPCRequest[Resp]) startMetrics() func(err error, rpcErr *RPCError) {
+	m := rpc.config.metrics
+	m.IncInFlight(rpc.method)
+	start := time.Now()
+
+	return func(err error, rpcErr *RPCError) {
+		m.DecInFlight(rpc.method)
+
+		if err == nil && rpcErr != nil {
+			err = rpcErr
+		}
+
+		m.ObserveDuration(rpc.method, time.Since(start), err)
+	}
+}
@@ -0,0 +1,72 @@
+package jsonrpc_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	errs     []error
+}
+
+func (m *recordingMetrics) IncInFlight(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[method]++
+}
+
+func (m *recordingMetrics) DecInFlight(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[method]--
+}
+
+func (m *recordingMetrics) ObserveDuration(method string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, err)
+}
+
+func TestExecuteWithMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/malformed":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":`)
+		case "/error":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"internal"},"id":1}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+		}
+	}))
+	defer server.Close()
+
+	metrics := &recordingMetrics{inFlight: map[string]int{}}
+	req := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{})
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithMetrics(metrics)).Execute(server.Client())
+	require.NoError(t, err)
+
+	_, err = req.Prepare(server.URL+"/malformed", jsonrpc.WithMetrics(metrics)).Execute(server.Client())
+	require.Error(t, err)
+
+	_, err = req.Prepare(server.URL+"/error", jsonrpc.WithMetrics(metrics)).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrInternalError)
+
+	// in-flight is balanced even when decoding fails
+	require.Equal(t, map[string]int{"getblock": 0}, metrics.inFlight)
+	require.Len(t, metrics.errs, 3)
+	require.NoError(t, metrics.errs[0])
+	require.Error(t, metrics.errs[1])
+	require.ErrorIs(t, metrics.errs[2], jsonrpc.ErrInternalError)
+}
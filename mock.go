@@ -0,0 +1,157 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/rotisserie/eris"
+)
+
+// MockTransport answers JSON-RPC calls in memory, for testing code built on
+// this package without a server. Methods are registered as on a Handler,
+// unknown ones are answered with -32601, and every call is captured for
+// assertions. Use it with WithTransport, or as the Transport of an
+// *http.Client passed to Execute. Requests must be plain POSTs, i.e.
+// neither compressed nor sent with WithHTTPMethod(GET).
+type MockTransport struct {
+	handler *Handler
+
+	mu    sync.Mutex
+	calls []MockCall
+}
+
+// MockCall is a captured call. ID is nil for notifications.
+type MockCall struct {
+	Method string
+	Params json.RawMessage
+	ID     json.RawMessage
+	Header http.Header
+}
+
+func NewMockTransport() *MockTransport {
+	return &MockTransport{handler: NewHandler()}
+}
+
+// Respond answers every call of method with result.
+func (m *MockTransport) Respond(method string, result any) {
+	m.handler.Handle(method, func(context.Context, json.RawMessage) (any, error) {
+		return result, nil
+	})
+}
+
+// Handle answers calls of method with fn, e.g. to compute the result from
+// the params or fail with an *RPCError.
+func (m *MockTransport) Handle(method string, fn MethodFunc) {
+	m.handler.Handle(method, fn)
+}
+
+// Calls returns the captured calls of method in the order they were made,
+// or of every method when method is empty.
+func (m *MockTransport) Calls(method string) []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []MockCall
+
+	for _, call := range m.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+
+		if err != nil {
+			return nil, eris.Wrap(err, "read mock request")
+		}
+	}
+
+	m.capture(body, req.Header)
+
+	served := req.Clone(req.Context())
+	served.Body = io.NopCloser(bytes.NewReader(body))
+
+	w := &mockWriter{header: make(http.Header)}
+	m.handler.ServeHTTP(w, served)
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// mockWriter records what the handler writes, keeping net/http/httptest out
+// of the package.
+type mockWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *mockWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *mockWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *mockWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	return w.body.Write(p)
+}
+
+func (m *MockTransport) capture(body []byte, header http.Header) {
+	var requests []serverRequest
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		_ = json.Unmarshal(body, &requests)
+	} else {
+		var single serverRequest
+		if json.Unmarshal(body, &single) == nil {
+			requests = append(requests, single)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, req := range requests {
+		m.calls = append(m.calls, MockCall{
+			Method: req.Method,
+			Params: req.Params,
+			ID:     req.ID,
+			Header: header.Clone(),
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockTransport(t *testing.T) {
+	t.Parallel()
+
+	mock := jsonrpc.NewMockTransport()
+	mock.Respond("getblockcount", 883189)
+	mock.Handle("getblockhash", func(_ context.Context, params json.RawMessage) (any, error) {
+		var height []int
+		if err := json.Unmarshal(params, &height); err != nil {
+			return nil, err
+		}
+
+		return map[int]string{883189: "0xabc"}[height[0]], nil
+	})
+
+	client := jsonrpc.NewClient("http://node.invalid", jsonrpc.WithTransport(mock), jsonrpc.WithDefaultHeader("Authorization", "Bearer t"))
+
+	var height int
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &height))
+	require.Equal(t, 883189, height)
+
+	hash, err := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{height}).Send(context.Background(), client)
+	require.NoError(t, err)
+	require.Equal(t, "0xabc", *hash)
+
+	err = client.Call(context.Background(), "getblock", []string{"0xabc"}, nil)
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+
+	calls := mock.Calls("getblockhash")
+	require.Len(t, calls, 1)
+	require.JSONEq(t, `[883189]`, string(calls[0].Params))
+	require.Equal(t, "Bearer t", calls[0].Header.Get("Authorization"))
+	require.Len(t, mock.Calls(""), 3)
+}
+
+func TestMockTransportBatch(t *testing.T) {
+	t.Parallel()
+
+	mock := jsonrpc.NewMockTransport()
+	mock.Respond("echo", "ok")
+
+	results, err := jsonrpc.NewBatch(
+		jsonrpc.NewRequest[[]int, string]("echo", []int{1}),
+		jsonrpc.NewRequest[[]int, string]("echo", []int{2}),
+	).Prepare("http://node.invalid").Execute(&http.Client{Transport: mock})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "ok", results[1].Result)
+
+	calls := mock.Calls("echo")
+	require.Len(t, calls, 2)
+	require.JSONEq(t, `[2]`, string(calls[1].Params))
+}
@@ -0,0 +1,83 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/rotisserie/eris"
+)
+
+// OneOf is a result that comes in one of two shapes, e.g. Bitcoin's
+// getblock, which returns an object or, at verbosity 0, a hex string. It
+// decodes into Primary when possible and into Secondary otherwise, so the
+// stricter type goes first; exactly one is set, or neither for null.
+//
+//	block, err := jsonrpc.NewRequest[[]any, jsonrpc.OneOf[Block, string]]("getblock", []any{hash, verbosity}).
+//		Prepare(url).Execute(nil)
+type OneOf[P any, S any] struct {
+	Primary   *P
+	Secondary *S
+}
+
+func (o *OneOf[P, S]) UnmarshalJSON(data []byte) error {
+	*o = OneOf[P, S]{}
+
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+
+	var primary P
+
+	errPrimary := json.Unmarshal(data, &primary)
+	if errPrimary == nil {
+		o.Primary = &primary
+		return nil
+	}
+
+	var secondary S
+	if err := json.Unmarshal(data, &secondary); err != nil {
+		return eris.Errorf("decode one of two types: %v; %v", errPrimary, err)
+	}
+
+	o.Secondary = &secondary
+
+	return nil
+}
+
+func (o OneOf[P, S]) MarshalJSON() ([]byte, error) {
+	if o.Primary != nil {
+		return json.Marshal(o.Primary)
+	}
+
+	return json.Marshal(o.Secondary)
+}
+
+// ResultDecodeError reports a well-formed response whose result does not
+// fit the result type. Result holds the raw result for decoding it another
+// way.
+type ResultDecodeError struct {
+	Result json.RawMessage
+	Err    error
+}
+
+func (e *ResultDecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResultDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// resultDecodeError tells a result that failed to decode, reported with its
+// raw bytes, from a malformed envelope.
+func resultDecodeError(codec Codec, raw string, err error) error {
+	var env struct {
+		Result json.RawMessage `json:"result"`
+	}
+
+	if unmarshalString(codec, raw, &env) != nil {
+		return eris.Wrap(err, "decode response")
+	}
+
+	return eris.Wrap(&ResultDecodeError{Result: env.Result, Err: err}, "decode response result")
+}
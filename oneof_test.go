@@ -0,0 +1,88 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type verboseBlock struct {
+	Hash   string `json:"hash"`
+	Height int    `json:"height"`
+}
+
+func newVerbosityServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []any `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Params[1] {
+		case float64(0):
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"00ff","id":1}`)
+		case float64(1):
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":{"hash":"0xabc","height":7},"id":1}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":null,"id":1}`)
+		}
+	}))
+}
+
+func TestExecuteOneOf(t *testing.T) {
+	t.Parallel()
+
+	server := newVerbosityServer()
+	defer server.Close()
+
+	getblock := func(verbosity int) *jsonrpc.OneOf[verboseBlock, string] {
+		res, err := jsonrpc.NewRequest[[]any, jsonrpc.OneOf[verboseBlock, string]](
+			"getblock", []any{"0xabc", verbosity}, jsonrpc.WithRPCid[[]any, jsonrpc.OneOf[verboseBlock, string]](1),
+		).Prepare(server.URL).Execute(server.Client())
+		require.NoError(t, err)
+
+		return res
+	}
+
+	verbose := getblock(1)
+	require.Equal(t, &verboseBlock{Hash: "0xabc", Height: 7}, verbose.Primary)
+	require.Nil(t, verbose.Secondary)
+
+	raw := getblock(0)
+	require.Nil(t, raw.Primary)
+	require.Equal(t, "00ff", *raw.Secondary)
+
+	null := getblock(2)
+	require.Nil(t, null.Primary)
+	require.Nil(t, null.Secondary)
+
+	encoded, err := json.Marshal(verbose)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"hash":"0xabc","height":7}`, string(encoded))
+}
+
+func TestExecuteResultDecodeErrorKeepsRawResult(t *testing.T) {
+	t.Parallel()
+
+	server := newVerbosityServer()
+	defer server.Close()
+
+	for _, opts := range [][]jsonrpc.PrepareOpt{nil, {jsonrpc.WithStrictDecode()}} {
+		_, err := jsonrpc.NewRequest[[]any, verboseBlock]("getblock", []any{"0xabc", 0}).
+			Prepare(server.URL, opts...).
+			Execute(server.Client())
+
+		var decodeErr *jsonrpc.ResultDecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		require.JSONEq(t, `"00ff"`, string(decodeErr.Result))
+
+		var hex string
+		require.NoError(t, json.Unmarshal(decodeErr.Result, &hex))
+		require.Equal(t, "00ff", hex)
+	}
+}
@@ -0,0 +1,301 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+type PipelineResult[Resp any] struct {
+	ID     any
+	Result *Resp
+	Err    error
+}
+
+// Pipeline fires prepared requests concurrently through a single pinned
+// client. Over an HTTP/2 transport the calls are multiplexed as streams on
+// one connection, which can beat protocol-level batching for servers that
+// process batch entries sequentially.
+type Pipeline[Resp any] struct {
+	client      *http.Client
+	concurrency int
+	requests    []*praparedRPCRequest[Resp]
+}
+
+func NewPipeline[Resp any](client *http.Client, concurrency int) *Pipeline[Resp] {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Pipeline[Resp]{client: client, concurrency: concurrency}
+}
+
+func (p *Pipeline[Resp]) Add(requests ...*praparedRPCRequest[Resp]) *Pipeline[Resp] {
+	p.requests = append(p.requests, requests...)
+
+	return p
+}
+
+// Execute runs all queued requests with at most p.concurrency in flight and
+// returns results in the order they were added. The queue is reset afterwards.
+func (p *Pipeline[Resp]) Execute() []PipelineResult[Resp] {
+	requests := p.requests
+	p.requests = nil
+
+	results := make([]PipelineResult[Resp], len(requests))
+
+	sem := make(chan struct{}, p.concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, req := range requests {
+		sem <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			res, err := req.Execute(p.client)
+			results[i] = PipelineResult[Resp]{ID: req.id, Result: res, Err: err}
+		})
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+var ErrPipelineBroken = eris.New("pipeline connection broken")
+
+// PipelineBrokenError fails the calls of a ConnPipeline that got no
+// response because the connection broke before, e.g. when the server closed
+// it. It matches ErrPipelineBroken with errors.Is and unwraps to the cause.
+type PipelineBrokenError struct {
+	Err error
+}
+
+func (e *PipelineBrokenError) Error() string {
+	return "pipeline connection broken: " + e.Err.Error()
+}
+
+func (e *PipelineBrokenError) Is(target error) bool {
+	return target == ErrPipelineBroken
+}
+
+func (e *PipelineBrokenError) Unwrap() error {
+	return e.Err
+}
+
+// ConnPipeline sends prepared requests back to back over one HTTP/1.1
+// keep-alive connection, without waiting for each response, and reads the
+// responses in the order the requests were sent, which HTTP/1.1 guarantees.
+// Unlike a batch the calls stay distinct requests; unlike Pipeline they
+// share a single socket and need no HTTP/2.
+//
+// Only the headers, body and decoding options of the prepared requests
+// apply; retries, rate limiting, hooks and the other options acting on
+// the HTTP client do not. A ConnPipeline is not safe for concurrent use.
+type ConnPipeline[Resp any] struct {
+	url      *url.URL
+	conn     net.Conn
+	reader   *bufio.Reader
+	requests []*praparedRPCRequest[Resp]
+
+	// err is set once the connection broke and fails every later call
+	err error
+}
+
+// DialPipeline opens the connection of a ConnPipeline to the host of an
+// http:// or https:// URL. ctx only bounds the dial.
+func DialPipeline[Resp any](ctx context.Context, rawURL string) (*ConnPipeline[Resp], error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, eris.Wrap(err, "parse pipeline url")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, eris.Errorf("unsupported pipeline scheme %q", u.Scheme)
+	}
+
+	conn, err := dialHost(ctx, u, u.Scheme == "https")
+	if err != nil {
+		return nil, eris.Wrap(&TransportError{Err: err}, "dial pipeline")
+	}
+
+	return &ConnPipeline[Resp]{url: u, conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Add queues requests, which must be prepared for the pipeline's scheme and
+// host; others fail without being sent.
+func (p *ConnPipeline[Resp]) Add(requests ...*praparedRPCRequest[Resp]) *ConnPipeline[Resp] {
+	p.requests = append(p.requests, requests...)
+
+	return p
+}
+
+// Execute sends the queued requests and returns a result per request in the
+// order they were added. The queue is reset afterwards.
+//
+// An RPC error, a non-2xx status or an undecodable result fails only its
+// own call. When the connection breaks, e.g. the server closes it or ctx is
+// done, the calls still waiting for a response fail with a
+// *PipelineBrokenError, as do all later ones: dial a new pipeline then.
+// Whether the server processed such calls is unknown, so only resend
+// idempotent ones.
+func (p *ConnPipeline[Resp]) Execute(ctx context.Context) []PipelineResult[Resp] {
+	requests := p.requests
+	p.requests = nil
+
+	results := make([]PipelineResult[Resp], len(requests))
+	sent := make([]*http.Request, len(requests))
+
+	for i, rpc := range requests {
+		results[i].ID = rpc.id
+
+		req, err := p.prepare(ctx, rpc)
+		if err != nil {
+			results[i].Err = rpc.wrap(err)
+			continue
+		}
+
+		sent[i] = req
+	}
+
+	// a done ctx unblocks the connection I/O by expiring its deadline
+	stop := context.AfterFunc(ctx, func() {
+		_ = p.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	written := make(chan struct{})
+
+	// written concurrently, as a server may answer before reading on and
+	// block on a full send buffer
+	go func() {
+		defer close(written)
+
+		if err := p.write(sent); err != nil {
+			_ = p.conn.Close()
+		}
+	}()
+
+	for i, req := range sent {
+		if req != nil {
+			results[i].Result, results[i].Err = p.read(requests[i], req)
+		}
+	}
+
+	if p.err != nil {
+		_ = p.conn.Close()
+	}
+
+	<-written
+
+	return results
+}
+
+// Close closes the connection.
+func (p *ConnPipeline[Resp]) Close() error {
+	return eris.Wrap(p.conn.Close(), "close pipeline")
+}
+
+func (p *ConnPipeline[Resp]) prepare(ctx context.Context, rpc *praparedRPCRequest[Resp]) (*http.Request, error) {
+	if rpc.err != nil {
+		return nil, eris.Wrap(rpc.err, "execute prepared request")
+	}
+
+	if rpc.internal.URL.Scheme != p.url.Scheme || rpc.internal.URL.Host != p.url.Host {
+		return nil, eris.Errorf("request not for pipeline host %s", p.url.Host)
+	}
+
+	req := rpc.internal.Clone(ctx)
+
+	if rpc.internal.GetBody != nil {
+		body, err := rpc.internal.GetBody()
+		if err != nil {
+			return nil, eris.Wrap(err, "get body")
+		}
+
+		req.Body = body
+	}
+
+	return req, nil
+}
+
+func (p *ConnPipeline[Resp]) write(requests []*http.Request) error {
+	w := bufio.NewWriter(p.conn)
+
+	for _, req := range requests {
+		if req == nil {
+			continue
+		}
+
+		if err := req.Write(w); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func (p *ConnPipeline[Resp]) read(rpc *praparedRPCRequest[Resp], req *http.Request) (*Resp, error) {
+	if p.err != nil {
+		return nil, rpc.wrap(&PipelineBrokenError{Err: p.err})
+	}
+
+	resp, err := http.ReadResponse(p.reader, req)
+	if err != nil {
+		p.err = err
+		return nil, rpc.wrap(&PipelineBrokenError{Err: err})
+	}
+
+	res, err := p.decode(rpc, resp)
+
+	// the next response starts where this body ends
+	if _, drainErr := io.Copy(io.Discard, resp.Body); drainErr != nil {
+		p.err = drainErr
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.Close && p.err == nil {
+		p.err = eris.New("server closed the connection")
+	}
+
+	return res, err
+}
+
+func (p *ConnPipeline[Resp]) decode(rpc *praparedRPCRequest[Resp], resp *http.Response) (*Resp, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, rpc.wrap(httpError(resp))
+	}
+
+	var result RPCResponse[Resp]
+	if err := rpc.decode(resp, &result); err != nil {
+		return nil, rpc.wrap(err)
+	}
+
+	if err := rpc.config.checkVersion(result.ID, result.JSONRPC); err != nil {
+		return nil, rpc.wrap(err)
+	}
+
+	if result.Error != nil {
+		return nil, rpc.wrap(result.Error)
+	}
+
+	if err := rpc.check(&result.Result); err != nil {
+		return nil, err
+	}
+
+	return &result.Result, nil
+}
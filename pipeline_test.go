@@ -0,0 +1,122 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// newPipelineServer doubles params[0], fails "fail" with an RPC error and
+// drops the connection on "drop". It counts the connections accepted.
+func newPipelineServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var conns atomic.Int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded struct {
+			Method string `json:"method"`
+			Params []int  `json:"params"`
+			ID     any    `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&decoded))
+
+		switch decoded.Method {
+		case "drop":
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+		case "fail":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","error":{"code":-8,"message":"out of range"},"id":%q}`, decoded.ID)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%d,"id":%q}`, decoded.Params[0]*2, decoded.ID)
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+
+	return server, &conns
+}
+
+func TestConnPipeline(t *testing.T) {
+	t.Parallel()
+
+	server, conns := newPipelineServer(t)
+	defer server.Close()
+
+	pipeline, err := jsonrpc.DialPipeline[int](context.Background(), server.URL)
+	require.NoError(t, err)
+	defer pipeline.Close()
+
+	for i, method := range []string{"double", "fail", "double", "double"} {
+		req := jsonrpc.NewRequest[[]int, int](method, []int{i}, jsonrpc.WithRPCid[[]int, int](fmt.Sprintf("p-%d", i)))
+		pipeline.Add(req.Prepare(server.URL))
+	}
+
+	// a request for another host is not sent
+	pipeline.Add(jsonrpc.NewRequest[[]int, int]("double", []int{9}).Prepare("http://other.invalid"))
+
+	results := pipeline.Execute(context.Background())
+	require.Len(t, results, 5)
+
+	require.NoError(t, results[0].Err)
+	require.Equal(t, 0, *results[0].Result)
+
+	// an RPC error fails only its own call
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, results[1].Err, &rpcErr)
+	require.Equal(t, -8, rpcErr.Code)
+
+	for i := 2; i < 4; i++ {
+		require.NoError(t, results[i].Err)
+		require.Equal(t, fmt.Sprintf("p-%d", i), results[i].ID)
+		require.Equal(t, i*2, *results[i].Result)
+	}
+
+	require.ErrorContains(t, results[4].Err, "not for pipeline host")
+
+	// the pipeline stays usable, still on the same connection
+	pipeline.Add(jsonrpc.NewRequest[[]int, int]("double", []int{21}).Prepare(server.URL))
+	results = pipeline.Execute(context.Background())
+	require.NoError(t, results[0].Err)
+	require.Equal(t, 42, *results[0].Result)
+
+	require.Equal(t, int32(1), conns.Load())
+}
+
+func TestConnPipelineBreaksMidway(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newPipelineServer(t)
+	defer server.Close()
+
+	pipeline, err := jsonrpc.DialPipeline[int](context.Background(), server.URL)
+	require.NoError(t, err)
+	defer pipeline.Close()
+
+	for i, method := range []string{"double", "drop", "double"} {
+		pipeline.Add(jsonrpc.NewRequest[[]int, int](method, []int{i}).Prepare(server.URL))
+	}
+
+	results := pipeline.Execute(context.Background())
+
+	require.NoError(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, jsonrpc.ErrPipelineBroken)
+	require.ErrorIs(t, results[2].Err, jsonrpc.ErrPipelineBroken)
+
+	// later calls fail too
+	pipeline.Add(jsonrpc.NewRequest[[]int, int]("double", []int{1}).Prepare(server.URL))
+	require.ErrorIs(t, pipeline.Execute(context.Background())[0].Err, jsonrpc.ErrPipelineBroken)
+}
@@ -0,0 +1,171 @@
+package jsonrpc
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+// Positional sends the fields of Args, a struct, as a positional params
+// array, as Bitcoin Core expects. Each field to send carries its index in a
+// position tag; the indexes must run from 0 without gaps. Trailing fields
+// tagged omitempty are left out while they hold their zero value, so
+// optional arguments at the end of a call need not be spelled out:
+//
+//	type getBlockParams struct {
+//		Hash      string `position:"0"`
+//		Verbosity *int   `position:"1,omitempty"`
+//	}
+//
+//	NewRequest[Positional[getBlockParams], Block]("getblock", Positional[getBlockParams]{Args: p})
+//
+// An omitempty field followed by one that is sent is sent too, as null or
+// its zero value, since positions cannot be skipped. Untagged fields are
+// ignored.
+type Positional[T any] struct {
+	Args T
+}
+
+// MarshalJSON encodes the arguments with the default codec. Sent as the
+// params of a request they are encoded with the request's codec instead.
+func (p Positional[T]) MarshalJSON() ([]byte, error) {
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+
+	return defaultCodec.Marshal(args)
+}
+
+// positionalParams is implemented by Positional, whatever its type argument.
+type positionalParams interface {
+	args() ([]any, error)
+}
+
+func (p Positional[T]) args() ([]any, error) {
+	return positionalArgs(reflect.ValueOf(p.Args))
+}
+
+type positionalArg struct {
+	value     any
+	omitEmpty bool
+	zero      bool
+}
+
+func positionalArgs(v reflect.Value) ([]any, error) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return []any{}, nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, eris.Errorf("positional params: %s is not a struct", v.Type())
+	}
+
+	t := v.Type()
+	byPos := make(map[int]positionalArg, t.NumField())
+
+	for i := range t.NumField() {
+		tag, ok := t.Field(i).Tag.Lookup("position")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if !t.Field(i).IsExported() {
+			return nil, eris.Errorf("positional params: field %s is not exported", t.Field(i).Name)
+		}
+
+		index, opts, _ := strings.Cut(tag, ",")
+
+		pos, err := strconv.Atoi(index)
+		if err != nil || pos < 0 {
+			return nil, eris.Errorf("positional params: field %s has invalid position %q", t.Field(i).Name, index)
+		}
+
+		if _, dup := byPos[pos]; dup {
+			return nil, eris.Errorf("positional params: position %d used twice", pos)
+		}
+
+		field := v.Field(i)
+		byPos[pos] = positionalArg{
+			value:     field.Interface(),
+			omitEmpty: opts == "omitempty",
+			zero:      field.IsZero(),
+		}
+	}
+
+	args := make([]any, len(byPos))
+	for pos := range args {
+		arg, ok := byPos[pos]
+		if !ok {
+			return nil, eris.Errorf("positional params: position %d is missing", pos)
+		}
+
+		args[pos] = arg.value
+	}
+
+	// only trailing optionals can go, as every position before one sent
+	// must be filled
+	n := len(args)
+	for n > 0 && byPos[n-1].omitEmpty && byPos[n-1].zero {
+		n--
+	}
+
+	return args[:n], nil
+}
+
+// ParamsBuilder assembles a positional params array whose trailing
+// arguments are optional, without conditional appends at the call site:
+//
+//	params := NewParams(hash).AddIf(verbose, 2)
+//	NewRequestWithParams[Block]("getblock", params)
+//
+// As with Positional, an argument skipped by AddIf is left out while only
+// skipped ones follow it, and is sent as null once a later one is added,
+// since positions cannot be skipped.
+type ParamsBuilder struct {
+	args []any
+	set  int
+}
+
+func NewParams(args ...any) *ParamsBuilder {
+	b := &ParamsBuilder{}
+	for _, arg := range args {
+		b.Add(arg)
+	}
+
+	return b
+}
+
+func (b *ParamsBuilder) Add(arg any) *ParamsBuilder {
+	b.args = append(b.args, arg)
+	b.set = len(b.args)
+
+	return b
+}
+
+// AddIf adds arg when cond holds and otherwise only takes its position.
+func (b *ParamsBuilder) AddIf(cond bool, arg any) *ParamsBuilder {
+	if cond {
+		return b.Add(arg)
+	}
+
+	b.args = append(b.args, nil)
+
+	return b
+}
+
+// Build returns the params array, never nil.
+func (b *ParamsBuilder) Build() []any {
+	return append([]any{}, b.args[:b.set]...)
+}
+
+// NewRequestWithParams creates a request with the params array of b.
+func NewRequestWithParams[Result any](method string, b *ParamsBuilder, opts ...RPCOpt[[]any, Result]) *rpcRequest[[]any, Result] {
+	return NewRequest(method, b.Build(), opts...)
+}
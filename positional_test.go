@@ -0,0 +1,179 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type getBlockParams struct {
+	Hash      string `position:"0"`
+	Verbosity *int   `position:"1,omitempty"`
+	Note      string
+}
+
+type rangeParams struct {
+	From  int    `position:"0"`
+	To    *int   `position:"1,omitempty"`
+	Label string `position:"2,omitempty"`
+}
+
+func TestPositionalParams(t *testing.T) {
+	t.Parallel()
+
+	two := 2
+
+	cases := []struct {
+		name   string
+		params any
+		want   string
+	}{
+		{
+			name:   "all set",
+			params: jsonrpc.Positional[getBlockParams]{Args: getBlockParams{Hash: "0000abc", Verbosity: &two, Note: "ignored"}},
+			want:   `["0000abc",2]`,
+		},
+		{
+			name:   "trailing optional omitted",
+			params: jsonrpc.Positional[getBlockParams]{Args: getBlockParams{Hash: "0000abc"}},
+			want:   `["0000abc"]`,
+		},
+		{
+			name:   "optional before a sent one is kept",
+			params: jsonrpc.Positional[rangeParams]{Args: rangeParams{From: 1, Label: "x"}},
+			want:   `[1,null,"x"]`,
+		},
+		{
+			name:   "pointer to struct",
+			params: jsonrpc.Positional[*rangeParams]{Args: &rangeParams{From: 0}},
+			want:   `[0]`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			encoded, err := json.Marshal(tc.params)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.want, string(encoded))
+		})
+	}
+}
+
+func TestPositionalParamsInvalidTags(t *testing.T) {
+	t.Parallel()
+
+	type gap struct {
+		A int `position:"0"`
+		B int `position:"2"`
+	}
+
+	type dup struct {
+		A int `position:"0"`
+		B int `position:"0"`
+	}
+
+	type bad struct {
+		A int `position:"first"`
+	}
+
+	_, err := json.Marshal(jsonrpc.Positional[gap]{})
+	require.ErrorContains(t, err, "position 1 is missing")
+
+	_, err = json.Marshal(jsonrpc.Positional[dup]{})
+	require.ErrorContains(t, err, "position 0 used twice")
+
+	_, err = json.Marshal(jsonrpc.Positional[bad]{})
+	require.ErrorContains(t, err, `invalid position "first"`)
+
+	_, err = json.Marshal(jsonrpc.Positional[int]{})
+	require.ErrorContains(t, err, "int is not a struct")
+}
+
+func TestPositionalParamsOnTheWire(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	params := jsonrpc.Positional[getBlockParams]{Args: getBlockParams{Hash: "0000abc"}}
+
+	_, err := jsonrpc.NewRequest[jsonrpc.Positional[getBlockParams], string]("getblock", params).
+		Prepare(server.URL).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, `["0000abc"]`, string((<-bodies)["params"]))
+}
+
+func TestPositionalParamsUseRequestCodec(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	type filterParams struct {
+		Filter map[string]int `position:"0"`
+	}
+
+	filter := map[string]int{}
+	for i := range 16 {
+		filter[fmt.Sprintf("key%02d", i)] = i
+	}
+
+	params := jsonrpc.Positional[filterParams]{Args: filterParams{Filter: filter}}
+
+	_, err := jsonrpc.NewRequest[jsonrpc.Positional[filterParams], string]("getlogs", params).
+		Prepare(server.URL, jsonrpc.WithCodec(jsonrpc.StdCodec)).
+		Execute(server.Client())
+	require.NoError(t, err)
+
+	// encoding/json sorts map keys, unlike the default codec
+	want, err := json.Marshal([]any{filter})
+	require.NoError(t, err)
+	require.Equal(t, string(want), string((<-bodies)["params"]))
+}
+
+func TestParamsBuilder(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		params *jsonrpc.ParamsBuilder
+		want   []any
+	}{
+		{name: "empty", params: jsonrpc.NewParams(), want: []any{}},
+		{name: "required only", params: jsonrpc.NewParams("0000abc"), want: []any{"0000abc"}},
+		{name: "trailing optional set", params: jsonrpc.NewParams("0000abc").AddIf(true, 2), want: []any{"0000abc", 2}},
+		{name: "trailing optional skipped", params: jsonrpc.NewParams("0000abc").AddIf(false, 2), want: []any{"0000abc"}},
+		{name: "gap filled with null", params: jsonrpc.NewParams("0000abc").AddIf(false, 2).Add(true), want: []any{"0000abc", nil, true}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, tc.params.Build())
+		})
+	}
+}
+
+func TestParamsBuilderOnTheWire(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]json.RawMessage, 1)
+	server := captureRequestBody(t, bodies)
+	defer server.Close()
+
+	verbosity := 0
+	_, err := jsonrpc.NewRequestWithParams[string]("getblock", jsonrpc.NewParams("0000abc").AddIf(verbosity != 0, verbosity)).
+		Prepare(server.URL).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, `["0000abc"]`, string((<-bodies)["params"]))
+}
@@ -3,51 +3,347 @@ package jsonrpc
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/rotisserie/eris"
 )
 
-type PrepareOpt func(*http.Request)
+type prepareConfig struct {
+	request *http.Request
+
+	onVersionMismatch func(got string)
+	requireVersion    string
+	preprocess        func([]byte) ([]byte, error)
+	strictBatch       bool
+	timeout           time.Duration
+	defaultTimeout    time.Duration
+	codec             Codec
+	maxResponseBytes  int64
+	retry             *retryConfig
+	retryStatuses     []int
+	retryCodes        []int
+	ndjson            bool
+	maxRetryAfter     time.Duration
+	limiter           RateLimiter
+	slots             chan struct{}
+	tracer            Tracer
+	clientTrace       *httptrace.ClientTrace
+	hook              Hook
+	hookBodies        bool
+	metrics           Metrics
+	compress          bool
+	compressMin       int
+	httpMethod        string
+
+	contentIdempotencyKey bool
+	streamBody            bool
+	debug                 io.Writer
+	strictDecode          bool
+	maxBatchSize          int
+	batchConcurrency      int
+	signer                func(body []byte, req *http.Request) error
+	beforeSend            func(ctx context.Context, method string, req *http.Request) error
+	afterResponse         func(resp *http.Response) error
+	requireJSON           bool
+	responseBufferSize    int
+	optionErr             error
+}
+
+type PrepareOpt func(*prepareConfig)
 
 func WithContext(ctx context.Context) PrepareOpt {
-	return func(r *http.Request) {
-		*r = *r.WithContext(ctx)
+	return func(c *prepareConfig) {
+		c.request = c.request.WithContext(ctx)
 	}
 }
 
 func WithContentType(contentType string) PrepareOpt {
-	return func(r *http.Request) {
-		r.Header.Set("Content-Type", contentType)
+	return func(c *prepareConfig) {
+		c.request.Header.Set("Content-Type", contentType)
 	}
 }
 
 func WithHeader(key, value string) PrepareOpt {
-	return func(r *http.Request) {
-		r.Header.Set(key, value)
+	return func(c *prepareConfig) {
+		c.request.Header.Set(key, value)
 	}
 }
 
-func (r *rpcRequest[Params, Resp]) Prepare(url string, opts ...PrepareOpt) *praparedRPCRequest[Resp] {
-	buff := bytes.NewBuffer(nil)
+// WithHost sends host as the Host header while still connecting to the URL's
+// address, e.g. to an IP behind a load balancer routing by host. It does not
+// change the TLS server name; set ServerName through WithTLSConfig for that.
+func WithHost(host string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.request.Host = host
+	}
+}
+
+// WithQueryParam appends key=value to the URL's query, e.g. for tenant
+// info kept out of the base URL. A query already in the URL is kept as is.
+func WithQueryParam(key, value string) PrepareOpt {
+	return func(c *prepareConfig) {
+		param := url.Values{key: {value}}.Encode()
+
+		if c.request.URL.RawQuery == "" {
+			c.request.URL.RawQuery = param
+		} else {
+			c.request.URL.RawQuery += "&" + param
+		}
+	}
+}
+
+// WithMaxResponseBytes caps the response body read by Execute; larger bodies
+// fail with ErrResponseTooLarge. n <= 0 removes the limit.
+func WithMaxResponseBytes(n int64) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithResponseBufferSize sets the initial capacity of the buffer the
+// response is read into when its size is not announced, e.g. for chunked or
+// compressed bodies, sparing the reallocations of growing it from scratch.
+// The decoded result may keep the whole buffer alive, so size it after the
+// expected responses. n <= 0 restores the default growth.
+func WithResponseBufferSize(n int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.responseBufferSize = n
+	}
+}
+
+func WithCodec(codec Codec) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.codec = codec
+	}
+}
+
+// WithStrictDecode fails the call when the result has members its type has
+// no field for, to catch schema drift. The envelope is still decoded
+// leniently. Custom codecs must implement UnmarshalStrict(data, v) for it.
+func WithStrictDecode() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.strictDecode = true
+	}
+}
+
+// WithRequireJSONContentType fails the call with a *ContentTypeError, which
+// quotes the start of the body, when a 2xx response is not application/json,
+// application/json-rpc or a +json type, parameters such as a charset aside,
+// rather than with a decode error. Off by default for
+// servers that send JSON under another type.
+func WithRequireJSONContentType() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.requireJSON = true
+	}
+}
+
+// DefaultUserAgent is sent unless a User-Agent is set through options.
+const DefaultUserAgent = "LiquidCats-jsonrpc/2"
+
+func WithUserAgent(ua string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.request.Header.Set("User-Agent", ua)
+	}
+}
+
+func WithBasicAuth(username, password string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.request.SetBasicAuth(username, password)
+	}
+}
+
+func WithBearerToken(token string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.request.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithTimeout bounds a single call. The deadline is derived from the request
+// context when the call is executed, so combined with WithContext the
+// earlier of the two deadlines applies.
+func WithTimeout(d time.Duration) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.timeout = d
+	}
+}
+
+// WithVersionMismatchWarning registers a callback invoked when the response
+// "jsonrpc" member differs from the version the request was sent with.
+// The call itself is not failed.
+func WithVersionMismatchWarning(fn func(got string)) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.onVersionMismatch = fn
+	}
+}
+
+// WithRequireVersion fails the call with a *VersionError when the response
+// omits the "jsonrpc" member or it differs from version, e.g. "2.0", which
+// catches misrouted responses. By default the member is not checked.
+func WithRequireVersion(version string) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.requireVersion = version
+	}
+}
+
+// checkVersion enforces WithRequireVersion on the response for id.
+func (c *prepareConfig) checkVersion(id any, got string) error {
+	if c.requireVersion == "" || got == c.requireVersion {
+		return nil
+	}
+
+	return &VersionError{ID: id, Want: c.requireVersion, Got: got}
+}
 
-	encoder := sonic.ConfigDefault.NewEncoder(buff)
+// WithResponsePreprocessor rewrites the raw response body before it is
+// decoded. It is an escape hatch for servers with minor protocol deviations.
+func WithResponsePreprocessor(fn func([]byte) ([]byte, error)) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.preprocess = fn
+	}
+}
 
-	if err := encoder.Encode(r); err != nil {
-		return &praparedRPCRequest[Resp]{err: eris.Wrap(err, "encode request data")}
+// WithStrictBatchCorrelation makes a batch Execute fail with ErrBatchMismatch
+// unless the response holds exactly one entry per requested id.
+func WithStrictBatchCorrelation() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.strictBatch = true
 	}
+}
+
+// WithNDJSONBatchResponse decodes a batch response sent as newline-delimited
+// JSON, one envelope per line, as some gateways do instead of an array.
+// Blank lines are skipped and entries are matched to requests by id.
+func WithNDJSONBatchResponse() PrepareOpt {
+	return func(c *prepareConfig) {
+		c.ndjson = true
+	}
+}
+
+// WithMaxBatchSize splits a batch of more than n requests into batches of
+// at most n, for servers capping the batch size. Execute sends them and
+// returns the results of all of them, in request order. n <= 0 disables it.
+func WithMaxBatchSize(n int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.maxBatchSize = n
+	}
+}
+
+// WithBatchConcurrency sends up to n of the batches split by
+// WithMaxBatchSize at once. By default they are sent one after another. The
+// first one to fail cancels the others.
+func WithBatchConcurrency(n int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.batchConcurrency = n
+	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, url, buff)
+func (r *rpcRequest[Params, Resp]) Prepare(url string, opts ...PrepareOpt) *praparedRPCRequest[Resp] {
+	prepared := &praparedRPCRequest[Resp]{
+		preparedHTTP: prepareHTTP(url, r.payload(), opts),
+		method:       r.Method,
+		version:      r.JSONRPC,
+		id:           r.ID,
+		validate:     r.validate,
+	}
+
+	// a GET may be cached or replayed, which a notification must not be
+	if prepared.err == nil && r.ID == nil && prepared.config.httpMethod == http.MethodGet {
+		prepared.err = eris.Wrap(ErrGETNotAllowed, "prepare notification")
+	}
+
+	return prepared
+}
+
+func prepareHTTP(url string, payload any, opts []PrepareOpt) preparedHTTP {
+	req, err := http.NewRequest(http.MethodPost, url, nil)
 	if err != nil {
-		return &praparedRPCRequest[Resp]{err: eris.Wrap(err, "create http request")}
+		return preparedHTTP{err: eris.Wrap(err, "create http request")}
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
+	cfg := newPrepareConfig(req, opts)
+	if cfg.optionErr != nil {
+		return preparedHTTP{err: cfg.optionErr}
+	}
+
+	if cfg.request.Header.Get("User-Agent") == "" {
+		cfg.request.Header.Set("User-Agent", DefaultUserAgent)
+	}
+
+	if cfg.streamBody && cfg.httpMethod == http.MethodPost {
+		setStreamedBody(cfg.request, cfg.codec, payload, cfg.compress)
+
+		return preparedHTTP{internal: cfg.request, config: cfg}
+	}
+
+	// encoded once options are known, as they may pick the codec
+	buff := bytes.NewBuffer(nil)
+
+	if err := cfg.codec.NewEncoder(buff).Encode(payload); err != nil {
+		return preparedHTTP{err: eris.Wrap(err, "encode request data")}
+	}
+
+	body := buff.Bytes()
+
+	setContentIdempotencyKey(cfg, body)
+
+	switch cfg.httpMethod {
+	case http.MethodPost:
+	case http.MethodGet:
+		if err := setQuery(cfg.request, body); err != nil {
+			return preparedHTTP{err: err}
+		}
+
+		return preparedHTTP{internal: cfg.request, config: cfg}
+	default:
+		return preparedHTTP{err: eris.Errorf("unsupported http method %q", cfg.httpMethod)}
+	}
+
+	if cfg.compress && len(body) >= cfg.compressMin {
+		compressed, err := gzipBytes(body)
+		if err != nil {
+			return preparedHTTP{err: err}
+		}
+
+		body = compressed
+		cfg.request.Header.Set("Content-Encoding", "gzip")
+	}
+
+	setBody(cfg.request, body)
+
+	return preparedHTTP{internal: cfg.request, config: cfg}
+}
+
+// newPrepareConfig applies the package defaults, then opts, to req.
+func newPrepareConfig(req *http.Request, opts []PrepareOpt) *prepareConfig {
+	cfg := &prepareConfig{
+		request:          req,
+		codec:            defaultCodec,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		maxRetryAfter:    DefaultMaxRetryAfter,
+		httpMethod:       http.MethodPost,
+	}
+
+	for _, opt := range defaultPrepareOpts() {
+		opt(cfg)
+	}
+
 	for _, opt := range opts {
-		opt(req)
+		opt(cfg)
 	}
 
-	return &praparedRPCRequest[Resp]{internal: req}
+	return cfg
+}
+
+func setBody(req *http.Request, data []byte) {
+	req.ContentLength = int64(len(data))
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
 }
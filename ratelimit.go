@@ -0,0 +1,132 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// RateLimiter is consulted before every request is sent, retries included.
+// Wait blocks until the request may proceed or ctx is done.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is the default RateLimiter: it refills at perSecond tokens per
+// second up to burst, and every request takes one token. A perSecond of zero
+// or less disables the limit rather than blocking forever.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(perSecond float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return eris.Wrap(err, "wait for token")
+	}
+
+	if b.rate <= 0 {
+		return nil
+	}
+
+	wait := b.reserve()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		b.refund()
+		return eris.Wrap(ctx.Err(), "wait for token")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token, letting the balance go negative, and returns how
+// long the caller has to wait until that token has been refilled.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// WithRateLimiter makes every call through the client wait on rl before it
+// is sent. The limiter is shared by all calls of the client.
+func WithRateLimiter(rl RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.opts = append(c.opts, func(cfg *prepareConfig) {
+			cfg.limiter = rl
+		})
+	}
+}
+
+// WithMaxConcurrent caps the calls of the client in flight at once to n,
+// however many goroutines make them. A call holds its slot from before it
+// is sent until its response is decoded, retries included, and waits for a
+// free slot until its context is done. Unlike a transport's MaxConnsPerHost
+// it bounds logical calls, so a batch takes one slot. Clones of the client
+// share the slots. n <= 0 disables it.
+func WithMaxConcurrent(n int) ClientOption {
+	return func(c *Client) {
+		var slots chan struct{}
+		if n > 0 {
+			slots = make(chan struct{}, n)
+		}
+
+		c.opts = append(c.opts, func(cfg *prepareConfig) {
+			cfg.slots = slots
+		})
+	}
+}
+
+// acquire takes a WithMaxConcurrent slot, if the request is capped, and
+// returns the func giving it back.
+func (rpc *preparedHTTP) acquire() (func(), error) {
+	if rpc.config.slots == nil {
+		return func() {}, nil
+	}
+
+	ctx := rpc.internal.Context()
+
+	select {
+	case rpc.config.slots <- struct{}{}:
+		return func() { <-rpc.config.slots }, nil
+	case <-ctx.Done():
+		return nil, eris.Wrap(ctx.Err(), "wait for call slot")
+	}
+}
@@ -0,0 +1,207 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRateLimiterSpacesCalls(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 8)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithRateLimiter(jsonrpc.NewTokenBucket(20, 1)),
+	)
+
+	start := time.Now()
+	for range 5 {
+		var res string
+		require.NoError(t, client.Call(context.Background(), "getblock", nil, &res))
+	}
+
+	// the first call uses the burst, the other four wait 50ms each
+	require.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+	require.Len(t, calls, 5)
+}
+
+func TestClientRateLimiterRespectsContext(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 8)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithHTTPClient(server.Client()),
+		jsonrpc.WithRateLimiter(jsonrpc.NewTokenBucket(0.01, 1)),
+	)
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblock", nil, &res))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Call(ctx, "getblock", nil, &res)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, calls, 1)
+}
+
+func TestTokenBucketRefundsCanceledWait(t *testing.T) {
+	t.Parallel()
+
+	bucket := jsonrpc.NewTokenBucket(10, 1)
+	require.NoError(t, bucket.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, bucket.Wait(ctx), context.DeadlineExceeded)
+
+	// the canceled caller must not push later callers further back
+	start := time.Now()
+	require.NoError(t, bucket.Wait(context.Background()))
+	require.Less(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestTokenBucketNonPositiveRateIsUnlimited(t *testing.T) {
+	t.Parallel()
+
+	for _, rate := range []float64{0, -1} {
+		bucket := jsonrpc.NewTokenBucket(rate, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for range 5 {
+			require.NoError(t, bucket.Wait(ctx))
+		}
+		cancel()
+	}
+}
+
+func TestClientMaxConcurrentCapsCallsInFlight(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, peak atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for cur := peak.Load(); n > cur && !peak.CompareAndSwap(cur, n); cur = peak.Load() {
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()), jsonrpc.WithMaxConcurrent(3))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			var res string
+			require.NoError(t, client.Call(context.Background(), "getblock", nil, &res))
+		})
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, peak.Load(), int32(3))
+	require.Positive(t, peak.Load())
+}
+
+func TestClientMaxConcurrentRespectsContext(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()), jsonrpc.WithMaxConcurrent(1))
+
+	go func() {
+		var res string
+		_ = client.Call(context.Background(), "getblock", nil, &res)
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var res string
+	err := client.Call(ctx, "getblock", nil, &res)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientMaxConcurrentSlotsPerClient(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// one option value given to two clients caps each of them on its own
+	limit := jsonrpc.WithMaxConcurrent(1)
+	first := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()), limit)
+	second := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()), limit)
+
+	for _, client := range []*jsonrpc.Client{first, second} {
+		go func() {
+			var res string
+			_ = client.Call(context.Background(), "getblock", nil, &res)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return len(started) == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestClientMaxConcurrentZeroIsUnlimited(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithHTTPClient(server.Client()), jsonrpc.WithMaxConcurrent(0))
+
+	for range 3 {
+		go func() {
+			var res string
+			_ = client.Call(context.Background(), "getblock", nil, &res)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return len(started) == 3 }, time.Second, 5*time.Millisecond)
+}
@@ -1,21 +1,61 @@
 package jsonrpc
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
 	"strconv"
-	"time"
 )
 
+// Version is the JSON-RPC protocol version sent with every request.
 const Version = "2.0"
 
+// ParamsMode controls how empty params (nil, zero-length slice or map,
+// struct without fields) are put on the wire.
+type ParamsMode int
+
+const (
+	// ParamsAsIs leaves it to the Params type and the omitempty tag: empty
+	// slices and maps are dropped, an empty struct is sent as {}, and when
+	// Params is an interface only nil is dropped.
+	ParamsAsIs ParamsMode = iota
+	OmitParams
+	EmptyArrayParams
+	EmptyObjectParams
+)
+
 type rpcRequest[Params any, Resp any] struct {
 	Method  string `json:"method"`
 	Params  Params `json:"params,omitempty"`
-	ID      any    `json:"id"`
-	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id,omitempty"`
+	JSONRPC string `json:"jsonrpc,omitempty"`
+
+	paramsMode ParamsMode
+	validate   func(*Resp) error
+}
+
+type rpcEnvelope struct {
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      any             `json:"id,omitempty"`
+	JSONRPC string          `json:"jsonrpc,omitempty"`
+}
+
+// rpcArgsEnvelope carries the arguments of Positional params, so the
+// request's codec encodes them.
+type rpcArgsEnvelope struct {
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+	ID      any    `json:"id,omitempty"`
+	JSONRPC string `json:"jsonrpc,omitempty"`
 }
 
 type RPCOpt[Params any, Resp any] func(*rpcRequest[Params, Resp])
 
+// WithRPCVersion sends version instead of Version, e.g. "1.0" for legacy
+// servers. An empty version leaves the "jsonrpc" member out altogether, for
+// JSON-RPC 1.0 servers rejecting unknown members.
 func WithRPCVersion[Params any, Resp any](version string) RPCOpt[Params, Resp] {
 	return func(req *rpcRequest[Params, Resp]) {
 		req.JSONRPC = version
@@ -28,9 +68,39 @@ func WithRPCid[Params any, Resp any](id any) RPCOpt[Params, Resp] {
 	}
 }
 
+func WithParamsMode[Params any, Resp any](mode ParamsMode) RPCOpt[Params, Resp] {
+	return func(req *rpcRequest[Params, Resp]) {
+		req.paramsMode = mode
+	}
+}
+
+// WithValidate checks every successfully decoded result with fn, e.g. for
+// a block with an empty hash, and fails the call with its error.
+func WithValidate[Params any, Resp any](fn func(*Resp) error) RPCOpt[Params, Resp] {
+	return func(req *rpcRequest[Params, Resp]) {
+		req.validate = fn
+	}
+}
+
 func NewRequest[Params any, Result any](method string, params Params, opts ...RPCOpt[Params, Result]) *rpcRequest[Params, Result] {
 	req := &rpcRequest[Params, Result]{
-		ID:      strconv.FormatInt(time.Now().UnixNano(), 10),
+		ID:      defaultIDGenerator.NextID(),
+		Method:  method,
+		JSONRPC: Version,
+		Params:  params,
+	}
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	return req
+}
+
+// NewNotification creates a request without an id. Per JSON-RPC 2.0 the
+// server must not reply to it, so it is meant to be sent with ExecuteNotification.
+func NewNotification[Params any](method string, params Params, opts ...RPCOpt[Params, struct{}]) *rpcRequest[Params, struct{}] {
+	req := &rpcRequest[Params, struct{}]{
 		Method:  method,
 		JSONRPC: Version,
 		Params:  params,
@@ -42,3 +112,100 @@ func NewRequest[Params any, Result any](method string, params Params, opts ...RP
 
 	return req
 }
+
+// payload returns the value to be encoded on the wire, applying the params mode.
+func (r *rpcRequest[Params, Resp]) payload() any {
+	// invalid Positional params are left to MarshalJSON to report
+	if p, ok := any(r.Params).(positionalParams); ok {
+		if args, err := p.args(); err == nil {
+			return &rpcArgsEnvelope{Method: r.Method, Params: args, ID: r.ID, JSONRPC: r.JSONRPC}
+		}
+	}
+
+	if r.paramsMode == ParamsAsIs || !isEmptyParams(r.Params) {
+		return r
+	}
+
+	env := &rpcEnvelope{
+		Method:  r.Method,
+		ID:      r.ID,
+		JSONRPC: r.JSONRPC,
+	}
+
+	switch r.paramsMode {
+	case EmptyArrayParams:
+		env.Params = json.RawMessage("[]")
+	case EmptyObjectParams:
+		env.Params = json.RawMessage("{}")
+	}
+
+	return env
+}
+
+func isEmptyParams(params any) bool {
+	v := reflect.ValueOf(params)
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Array:
+		return v.Len() == 0
+	case reflect.Struct:
+		return v.NumField() == 0
+	default:
+		return false
+	}
+}
+
+// SameID reports whether two ids are the same once JSON number typing is
+// set aside: the int64 123 sent, the float64 123 it decodes to and the
+// string "123" all match. Responses are correlated to requests this way.
+func SameID(a, b any) bool {
+	return idKey(a) == idKey(b)
+}
+
+// idKey turns an id into a map key. Responses are matched by the textual
+// form of the id, so the 7 sent and the 7.0 decoded compare equal. Floats
+// are written out in full, as %v would put large integral ones, e.g. a
+// decoded 1<<60, in exponent form.
+func idKey(id any) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case float64:
+		return floatKey(v)
+	case float32:
+		return floatKey(float64(v))
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return floatKey(f)
+		}
+
+		return v.String()
+	default:
+		return fmt.Sprint(id)
+	}
+}
+
+// floatKey writes integral floats as the exact integer they hold.
+func floatKey(f float64) string {
+	switch {
+	case f != math.Trunc(f):
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	case f >= -(1<<63) && f < 1<<63:
+		return strconv.FormatInt(int64(f), 10)
+	case f >= 0 && f < 1<<64:
+		return strconv.FormatUint(uint64(f), 10)
+	default:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+}
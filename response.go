@@ -1,19 +1,156 @@
 package jsonrpc
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+var (
+	ErrParseError     = &RPCError{Code: CodeParseError, Message: "Parse error"}
+	ErrInvalidRequest = &RPCError{Code: CodeInvalidRequest, Message: "Invalid Request"}
+	ErrMethodNotFound = &RPCError{Code: CodeMethodNotFound, Message: "Method not found"}
+	ErrInvalidParams  = &RPCError{Code: CodeInvalidParams, Message: "Invalid params"}
+	ErrInternalError  = &RPCError{Code: CodeInternalError, Message: "Internal error"}
+)
 
 type RPCResponse[D any] struct {
 	JSONRPC string    `json:"jsonrpc"`
 	Result  D         `json:"result"`
 	Error   *RPCError `json:"error,omitempty"`
 	ID      any       `json:"id"`
+
+	// null is set while decoding when the result was null or missing, which
+	// Result alone cannot tell from a zero value.
+	null bool
+
+	// elapsed and decoding are measured by the call that filled the response.
+	elapsed  time.Duration
+	decoding time.Duration
+
+	// each, set by ExecuteEach, takes the result's elements one at a time
+	// instead of Result.
+	each func(raw []byte) error
 }
 
 type RPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
 func (e *RPCError) Error() string {
 	return fmt.Sprintf("jsonrpc error: code=%d, message=%s", e.Code, e.Message)
 }
+
+// Is matches another *RPCError by code, so errors.Is(err, ErrMethodNotFound)
+// holds for any server error with code -32601 regardless of its message.
+func (e *RPCError) Is(target error) bool {
+	t, ok := target.(*RPCError)
+	if !ok {
+		return false
+	}
+
+	return e.Code == t.Code
+}
+
+// IsMethodNotFound reports whether err wraps an *RPCError with code -32601,
+// e.g. to detect a node lacking a method.
+func IsMethodNotFound(err error) bool {
+	return errors.Is(err, ErrMethodNotFound)
+}
+
+// IsInvalidParams reports whether err wraps an *RPCError with code -32602.
+func IsInvalidParams(err error) bool {
+	return errors.Is(err, ErrInvalidParams)
+}
+
+// IsParseError reports whether err wraps an *RPCError with code -32700.
+func IsParseError(err error) bool {
+	return errors.Is(err, ErrParseError)
+}
+
+// IsStandard reports whether the error carries one of the codes predefined
+// by the JSON-RPC 2.0 specification.
+func (e *RPCError) IsStandard() bool {
+	switch e.Code {
+	case CodeParseError, CodeInvalidRequest, CodeMethodNotFound, CodeInvalidParams, CodeInternalError:
+		return true
+	default:
+		return false
+	}
+}
+
+// DataAs decodes the optional "data" member of the error into v.
+func (e *RPCError) DataAs(v any) error {
+	if len(e.Data) == 0 {
+		return eris.New("rpc error has no data")
+	}
+
+	if err := defaultCodec.Unmarshal(e.Data, v); err != nil {
+		return eris.Wrap(err, "decode rpc error data")
+	}
+
+	return nil
+}
+
+var ErrAmbiguousResponse = eris.New("response has both result and error")
+
+// AmbiguousResponseError reports a response carrying both a non-null result
+// and an error, which JSON-RPC 2.0 forbids. It matches ErrAmbiguousResponse
+// with errors.Is and unwraps to the server's *RPCError.
+type AmbiguousResponseError struct {
+	ID  any
+	Err *RPCError
+}
+
+func (e *AmbiguousResponseError) Error() string {
+	return fmt.Sprintf("response for id %v has both result and error: %s", e.ID, e.Err.Error())
+}
+
+func (e *AmbiguousResponseError) Is(target error) bool {
+	return target == ErrAmbiguousResponse
+}
+
+func (e *AmbiguousResponseError) Unwrap() error {
+	return e.Err
+}
+
+var ErrVersionMismatch = eris.New("unexpected response jsonrpc version")
+
+// VersionError is returned under WithRequireVersion for a response whose
+// "jsonrpc" member is missing or differs. Got is empty when it was missing.
+// It matches ErrVersionMismatch with errors.Is.
+type VersionError struct {
+	ID   any
+	Want string
+	Got  string
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("response for id %v has jsonrpc version %q, want %q", e.ID, e.Got, e.Want)
+}
+
+func (e *VersionError) Is(target error) bool {
+	return target == ErrVersionMismatch
+}
+
+// resultPresence records whether a non-null result member was present
+// without keeping it.
+type resultPresence bool
+
+func (p *resultPresence) UnmarshalJSON(data []byte) error {
+	*p = string(data) != "null"
+	return nil
+}
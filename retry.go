@@ -0,0 +1,163 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// BackoffFunc returns the delay before the next attempt; attempt starts at 1
+// for the wait after the first failure.
+type BackoffFunc func(attempt int) time.Duration
+
+// ExponentialBackoff doubles base on every attempt, capped at maxDelay.
+func ExponentialBackoff(base, maxDelay time.Duration) BackoffFunc {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+
+		return min(delay, maxDelay)
+	}
+}
+
+var defaultBackoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultMaxRetryAfter caps the wait a server can request through
+// Retry-After unless overridden by WithMaxRetryAfter.
+const DefaultMaxRetryAfter = time.Minute
+
+type retryConfig struct {
+	maxAttempts int
+	backoff     BackoffFunc
+}
+
+// WithRetry retries network errors and retryable HTTP statuses (429, 502, 503
+// and 504 unless overridden by WithRetryableStatuses) up to maxAttempts in
+// total. A Retry-After header on the failed response takes precedence over
+// backoff; a nil backoff uses ExponentialBackoff(100ms, 5s). The context's
+// deadline and WithTimeout bound all attempts together: no wait is started
+// that would pass them, the last failure being returned instead.
+func WithRetry(maxAttempts int, backoff BackoffFunc) PrepareOpt {
+	return func(c *prepareConfig) {
+		if backoff == nil {
+			backoff = defaultBackoff
+		}
+
+		c.retry = &retryConfig{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+func WithMaxRetryAfter(d time.Duration) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.maxRetryAfter = d
+	}
+}
+
+func WithRetryableStatuses(codes ...int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.retryStatuses = codes
+	}
+}
+
+// WithRetryableCodes makes WithRetry also retry a call answered with an RPC
+// error of one of codes, e.g. bitcoind's -28 while the node is warming up.
+// These retries are counted apart from those of HTTP and network failures,
+// each up to maxAttempts. Batches are not retried by code.
+func WithRetryableCodes(codes ...int) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.retryCodes = codes
+	}
+}
+
+func (c *prepareConfig) shouldRetryCode(ctx context.Context, attempt int, rpcErr *RPCError) bool {
+	if c.retry == nil || rpcErr == nil || attempt >= c.retry.maxAttempts || ctx.Err() != nil {
+		return false
+	}
+
+	return slices.Contains(c.retryCodes, rpcErr.Code)
+}
+
+func (c *prepareConfig) shouldRetry(ctx context.Context, attempt int, resp *http.Response, err error) bool {
+	if c.retry == nil || attempt >= c.retry.maxAttempts || ctx.Err() != nil {
+		return false
+	}
+
+	// without a response only transport failures are retried; errors raised
+	// before sending, e.g. by the signer, would only repeat
+	if resp == nil {
+		return errors.Is(err, ErrTransport)
+	}
+
+	statuses := c.retryStatuses
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+
+	return slices.Contains(statuses, resp.StatusCode)
+}
+
+func (c *prepareConfig) retryDelay(attempt int, cause error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(cause, &httpErr) && httpErr.RetryAfter > 0 {
+		return min(httpErr.RetryAfter, c.maxRetryAfter)
+	}
+
+	return c.retry.backoff(attempt)
+}
+
+// retryFits reports whether waiting delay leaves the context's deadline
+// unpassed, so that retries stay within the overall budget of the call.
+func retryFits(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+
+	return !ok || time.Until(deadline) > delay
+}
+
+func waitRetry(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return eris.Wrap(ctx.Err(), "wait for retry")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter accepts both the delay-seconds and the HTTP-date form of
+// Retry-After. Dates in the past yield a zero delay.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(date.Sub(now), 0), true
+}
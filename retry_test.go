@@ -0,0 +1,324 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyServer answers the first failures attempts with fail and every
+// later one with a valid response, recording each request body it saw.
+func newFlakyServer(failures int32, fail func(w http.ResponseWriter)) (*httptest.Server, *atomic.Int32, chan string) {
+	var attempts atomic.Int32
+	bodies := make(chan string, 16)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+
+		if attempts.Add(1) <= failures {
+			fail(w)
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}`))
+	}))
+
+	return server, &attempts, bodies
+}
+
+func failWith(status int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+	}
+}
+
+func TestExecuteRetriesRetryableStatus(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, bodies := newFlakyServer(2, failWith(http.StatusServiceUnavailable))
+	defer server.Close()
+
+	res, err := jsonrpc.NewRequest[[]int, string]("flaky", []int{1, 2}, jsonrpc.WithRPCid[[]int, string](1)).
+		Prepare(server.URL, jsonrpc.WithRetry(3, jsonrpc.ExponentialBackoff(time.Millisecond, 5*time.Millisecond))).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.EqualValues(t, 3, attempts.Load())
+
+	close(bodies)
+	for body := range bodies {
+		require.JSONEq(t, `{"jsonrpc":"2.0","method":"flaky","params":[1,2],"id":1}`, body)
+	}
+}
+
+func TestExecuteRetriesNetworkError(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(1, func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	})
+	defer server.Close()
+
+	res, err := jsonrpc.NewRequest[struct{}, string]("flaky", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithRetry(2, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.EqualValues(t, 2, attempts.Load())
+}
+
+func TestExecuteRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(10, failWith(http.StatusBadGateway))
+	defer server.Close()
+
+	_, err := jsonrpc.NewRequest[struct{}, string]("flaky", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithRetry(3, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))).
+		Execute(server.Client())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "http status 502")
+	require.EqualValues(t, 3, attempts.Load())
+}
+
+func TestExecuteRetryableStatuses(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(1, failWith(http.StatusBadRequest))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("flaky", struct{}{})
+	backoff := jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond)
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithRetry(3, backoff)).Execute(server.Client())
+	require.Error(t, err)
+	require.EqualValues(t, 1, attempts.Load())
+
+	attempts.Store(0)
+
+	res, err := req.Prepare(server.URL,
+		jsonrpc.WithRetry(3, backoff),
+		jsonrpc.WithRetryableStatuses(http.StatusBadRequest),
+	).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.EqualValues(t, 2, attempts.Load())
+}
+
+func TestExecuteRetryableCodes(t *testing.T) {
+	t.Parallel()
+
+	warmingUp := func(w http.ResponseWriter) {
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-28,"message":"Loading block index..."},"id":1}`))
+	}
+
+	server, attempts, _ := newFlakyServer(2, warmingUp)
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{})
+	backoff := jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond)
+
+	_, err := req.Prepare(server.URL, jsonrpc.WithRetry(3, backoff)).Execute(server.Client())
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -28, rpcErr.Code)
+	require.EqualValues(t, 1, attempts.Load())
+
+	res, err := req.Prepare(server.URL,
+		jsonrpc.WithRetry(3, backoff),
+		jsonrpc.WithRetryableCodes(-28),
+	).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.EqualValues(t, 3, attempts.Load())
+
+	attempts.Store(0)
+
+	// attempts run out before the node is ready
+	full, err := req.Prepare(server.URL,
+		jsonrpc.WithRetry(2, backoff),
+		jsonrpc.WithRetryableCodes(-28),
+	).ExecuteFull(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, -28, full.Error.Code)
+	require.EqualValues(t, 2, attempts.Load())
+}
+
+func TestExecuteRetryableCodesStayWithinTimeout(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-28,"message":"Loading block index..."},"id":1}`))
+	}))
+	defer server.Close()
+
+	var prepared atomic.Int32
+
+	start := time.Now()
+	_, err := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{}).
+		Prepare(server.URL,
+			jsonrpc.WithTimeout(200*time.Millisecond),
+			jsonrpc.WithRetry(100, jsonrpc.ExponentialBackoff(10*time.Millisecond, 10*time.Millisecond)),
+			jsonrpc.WithRetryableCodes(-28),
+			jsonrpc.WithBeforeSend(func(context.Context, string, *http.Request) error {
+				prepared.Add(1)
+				return nil
+			}),
+		).
+		Execute(server.Client())
+	require.Error(t, err)
+
+	// the timeout bounds all attempts together, not each of them
+	require.Less(t, time.Since(start), time.Second)
+	require.Less(t, attempts.Load(), int32(100))
+	require.EqualValues(t, 1, prepared.Load())
+}
+
+func TestExecuteRetryStopsOnContextCancel(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(10, failWith(http.StatusServiceUnavailable))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := jsonrpc.NewRequest[struct{}, string]("flaky", struct{}{}).
+		Prepare(server.URL,
+			jsonrpc.WithContext(ctx),
+			jsonrpc.WithRetry(5, jsonrpc.ExponentialBackoff(time.Hour, time.Hour)),
+		).
+		Execute(server.Client())
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.EqualValues(t, 1, attempts.Load())
+}
+
+func TestExecuteRetryStaysWithinDeadline(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(100, failWith(http.StatusServiceUnavailable))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// waits of 100, 200 and 400ms: the third would pass the deadline
+	start := time.Now()
+	_, err := jsonrpc.NewRequest[struct{}, string]("flaky", struct{}{}).
+		Prepare(server.URL,
+			jsonrpc.WithContext(ctx),
+			jsonrpc.WithRetry(10, jsonrpc.ExponentialBackoff(100*time.Millisecond, time.Second)),
+		).
+		Execute(server.Client())
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.EqualValues(t, 3, attempts.Load())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	backoff := jsonrpc.ExponentialBackoff(100*time.Millisecond, time.Second)
+
+	require.Equal(t, 100*time.Millisecond, backoff(1))
+	require.Equal(t, 200*time.Millisecond, backoff(2))
+	require.Equal(t, 400*time.Millisecond, backoff(3))
+	require.Equal(t, time.Second, backoff(5))
+	require.Equal(t, time.Second, backoff(60))
+}
+
+func TestExecuteRetryHonoursRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(1, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	start := time.Now()
+	res, err := jsonrpc.NewRequest[struct{}, string]("throttled", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithRetry(2, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond))).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.EqualValues(t, 2, attempts.Load())
+	require.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+}
+
+func TestExecuteRetryAfterIsCapped(t *testing.T) {
+	t.Parallel()
+
+	server, attempts, _ := newFlakyServer(1, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer server.Close()
+
+	start := time.Now()
+	_, err := jsonrpc.NewRequest[struct{}, string]("throttled", struct{}{}).
+		Prepare(server.URL,
+			jsonrpc.WithRetry(2, nil),
+			jsonrpc.WithMaxRetryAfter(10*time.Millisecond),
+		).
+		Execute(server.Client())
+	require.NoError(t, err)
+	require.EqualValues(t, 2, attempts.Load())
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExecuteSurfacesRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	retryAt := time.Now().Add(30 * time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/date":
+			w.Header().Set("Retry-After", retryAt.UTC().Format(http.TimeFormat))
+		case "/seconds":
+			w.Header().Set("Retry-After", "2")
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	req := jsonrpc.NewRequest[struct{}, string]("throttled", struct{}{})
+
+	var httpErr *jsonrpc.HTTPError
+
+	_, err := req.Prepare(server.URL + "/seconds").Execute(server.Client())
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusTooManyRequests, httpErr.StatusCode)
+	require.Equal(t, 2*time.Second, httpErr.RetryAfter)
+
+	_, err = req.Prepare(server.URL + "/date").Execute(server.Client())
+	require.ErrorAs(t, err, &httpErr)
+	require.InDelta(t, 30*time.Second, httpErr.RetryAfter, float64(3*time.Second))
+
+	_, err = req.Prepare(server.URL + "/none").Execute(server.Client())
+	require.ErrorAs(t, err, &httpErr)
+	require.Zero(t, httpErr.RetryAfter)
+}
@@ -0,0 +1,229 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// MethodFunc handles one call with its raw params, which are nil when the
+// request had none.
+type MethodFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Handler serves JSON-RPC 2.0 over HTTP, both single requests and batches.
+// Requests without an id are notifications and get no response. A method
+// returning an *RPCError has it sent as is; any other error is reported as
+// a generic internal error so no details leak to callers.
+type Handler struct {
+	codec    Codec
+	maxBytes int64
+
+	mu      sync.RWMutex
+	methods map[string]MethodFunc
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		codec:    defaultCodec,
+		maxBytes: DefaultMaxResponseBytes,
+		methods:  make(map[string]MethodFunc),
+	}
+}
+
+// Register adds a typed method to h. Params that do not decode into Params
+// are answered with -32602.
+func Register[Params, Result any](h *Handler, method string, fn func(ctx context.Context, params Params) (Result, error)) {
+	h.Handle(method, func(ctx context.Context, raw json.RawMessage) (any, error) {
+		var params Params
+		if len(raw) > 0 {
+			if err := h.codec.Unmarshal(raw, &params); err != nil {
+				return nil, &RPCError{Code: CodeInvalidParams, Message: ErrInvalidParams.Message}
+			}
+		}
+
+		return fn(ctx, params)
+	})
+}
+
+// Handle adds an untyped method to h.
+func (h *Handler) Handle(method string, fn MethodFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.methods[method] = fn
+}
+
+// SetMaxRequestBytes bounds request bodies, DefaultMaxResponseBytes unless
+// set; larger ones are answered with 413. Zero or less lifts the bound. It
+// must be called before h serves requests.
+func (h *Handler) SetMaxRequestBytes(n int64) {
+	h.maxBytes = n
+}
+
+// serverRequest keeps id raw so it is echoed back exactly as sent.
+type serverRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// serverResponse mirrors RPCResponse, but leaves out result on errors as the
+// specification requires.
+type serverResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var nullID = json.RawMessage("null")
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, "read request", http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+
+	var reply any
+
+	switch {
+	case !json.Valid(body):
+		reply = errorResponse(nullID, ErrParseError)
+	case len(body) > 0 && body[0] == '[':
+		if replies := h.serveBatch(r.Context(), body); replies != nil {
+			reply = replies
+		}
+	default:
+		if res, ok := h.serve(r.Context(), body); ok {
+			reply = res
+		}
+	}
+
+	// notifications, alone or as a whole batch, get an empty response
+	if reply == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	out, err := h.codec.Marshal(reply)
+	if err != nil {
+		out, _ = h.codec.Marshal(errorResponse(nullID, ErrInternalError))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// serveBatch returns the replies in request order without the notifications,
+// or nil when there is nothing to reply with. An empty batch is itself an
+// invalid request and answered with a single error object.
+func (h *Handler) serveBatch(ctx context.Context, body []byte) any {
+	var entries []json.RawMessage
+	if err := h.codec.Unmarshal(body, &entries); err != nil {
+		return errorResponse(nullID, ErrParseError)
+	}
+
+	if len(entries) == 0 {
+		return errorResponse(nullID, ErrInvalidRequest)
+	}
+
+	replies := make([]serverResponse, 0, len(entries))
+	for _, entry := range entries {
+		if res, ok := h.serve(ctx, entry); ok {
+			replies = append(replies, res)
+		}
+	}
+
+	if len(replies) == 0 {
+		return nil
+	}
+
+	return replies
+}
+
+// serve runs a single request. It reports false for notifications, which
+// are executed but never answered, not even with an error.
+func (h *Handler) serve(ctx context.Context, raw []byte) (serverResponse, bool) {
+	var req serverRequest
+	if err := h.codec.Unmarshal(raw, &req); err != nil || req.JSONRPC != Version || req.Method == "" || !isValidID(req.ID) {
+		id := req.ID
+		if len(id) == 0 || !isValidID(id) {
+			id = nullID
+		}
+
+		return errorResponse(id, ErrInvalidRequest), true
+	}
+
+	notification := len(req.ID) == 0
+
+	h.mu.RLock()
+	fn, ok := h.methods[req.Method]
+	h.mu.RUnlock()
+
+	if !ok {
+		return errorResponse(req.ID, ErrMethodNotFound), !notification
+	}
+
+	result, err := fn(ctx, req.Params)
+	if notification {
+		return serverResponse{}, false
+	}
+
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = ErrInternalError
+		}
+
+		return errorResponse(req.ID, rpcErr), true
+	}
+
+	encoded, err := h.codec.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, ErrInternalError), true
+	}
+
+	return serverResponse{JSONRPC: Version, Result: encoded, ID: req.ID}, true
+}
+
+func errorResponse(id json.RawMessage, err *RPCError) serverResponse {
+	return serverResponse{JSONRPC: Version, Error: err, ID: id}
+}
+
+// isValidID reports whether id is absent or a string, number or null.
+func isValidID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+
+	switch id[0] {
+	case '"', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'n':
+		return true
+	default:
+		return false
+	}
+}
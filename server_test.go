@@ -0,0 +1,225 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler() *jsonrpc.Handler {
+	h := jsonrpc.NewHandler()
+
+	jsonrpc.Register(h, "add", func(ctx context.Context, params []int) (int, error) {
+		sum := 0
+		for _, n := range params {
+			sum += n
+		}
+
+		return sum, nil
+	})
+
+	jsonrpc.Register(h, "fail", func(ctx context.Context, params struct{ Custom bool }) (any, error) {
+		if params.Custom {
+			return nil, &jsonrpc.RPCError{Code: -32000, Message: "insufficient funds"}
+		}
+
+		return nil, errors.New("database password leaked")
+	})
+
+	return h
+}
+
+func postRaw(t *testing.T, url, body string) string {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	out, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(out)
+}
+
+func TestHandlerServesClientCalls(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+
+	res, err := jsonrpc.NewRequest[[]int, int]("add", []int{1, 2, 3}).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, 6, *res)
+
+	_, err = jsonrpc.NewRequest[[]int, int]("sub", []int{1}).Prepare(server.URL).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+
+	_, err = jsonrpc.NewRequest[string, int]("add", "one").Prepare(server.URL).Execute(server.Client())
+	require.ErrorIs(t, err, jsonrpc.ErrInvalidParams)
+
+	_, err = jsonrpc.NewRequest[map[string]bool, any]("fail", map[string]bool{"Custom": true}).
+		Prepare(server.URL).Execute(server.Client())
+
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32000, rpcErr.Code)
+	require.Equal(t, "insufficient funds", rpcErr.Message)
+
+	_, err = jsonrpc.NewRequest[map[string]bool, any]("fail", map[string]bool{}).
+		Prepare(server.URL).Execute(server.Client())
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc.CodeInternalError, rpcErr.Code)
+	require.NotContains(t, rpcErr.Message, "password")
+}
+
+func TestHandlerMalformedInput(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+
+	cases := map[string]struct {
+		body string
+		want string
+	}{
+		"parse error": {
+			body: `{"jsonrpc":"2.0","method":"add",`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+		"wrong version": {
+			body: `{"jsonrpc":"1.0","method":"add","id":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`,
+		},
+		"method not a string": {
+			body: `{"jsonrpc":"2.0","id":"a","method":1}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":"a"}`,
+		},
+		"object id": {
+			body: `{"jsonrpc":"2.0","method":"add","id":{}}`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		"not an object": {
+			body: `42`,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.JSONEq(t, tc.want, postRaw(t, server.URL, tc.body))
+		})
+	}
+}
+
+func TestHandlerBatch(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+
+	got := postRaw(t, server.URL, `[
+		{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1},
+		{"jsonrpc":"2.0","method":"nope","id":"2"},
+		1
+	]`)
+
+	require.JSONEq(t, `[
+		{"jsonrpc":"2.0","result":3,"id":1},
+		{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"2"},
+		{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}
+	]`, got)
+
+	responses, err := jsonrpc.NewBatch(
+		jsonrpc.NewRequest[[]int, int]("add", []int{1, 1}),
+		jsonrpc.NewRequest[[]int, int]("add", []int{2, 2}),
+	).Prepare(server.URL).Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, 2, responses[0].Result)
+	require.Equal(t, 4, responses[1].Result)
+}
+
+func TestHandlerNotifications(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+
+	notified := make(chan string, 8)
+	jsonrpc.Register(h, "log", func(ctx context.Context, params []string) (any, error) {
+		notified <- params[0]
+		return nil, nil
+	})
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	require.Empty(t, postRaw(t, server.URL, `{"jsonrpc":"2.0","method":"log","params":["single"]}`))
+	require.Equal(t, "single", <-notified)
+
+	// notifications are never answered, not even with errors
+	require.Empty(t, postRaw(t, server.URL, `{"jsonrpc":"2.0","method":"nope"}`))
+
+	require.Empty(t, postRaw(t, server.URL, `[
+		{"jsonrpc":"2.0","method":"log","params":["a"]},
+		{"jsonrpc":"2.0","method":"log","params":["b"]}
+	]`))
+	require.Equal(t, "a", <-notified)
+	require.Equal(t, "b", <-notified)
+
+	got := postRaw(t, server.URL, `[
+		{"jsonrpc":"2.0","method":"log","params":["c"]},
+		{"jsonrpc":"2.0","method":"add","params":[2,3],"id":7},
+		{"jsonrpc":"2.0","method":"add","params":[1],"id":null}
+	]`)
+	require.JSONEq(t, `[
+		{"jsonrpc":"2.0","result":5,"id":7},
+		{"jsonrpc":"2.0","result":1,"id":null}
+	]`, got)
+	require.Equal(t, "c", <-notified)
+
+	require.NoError(t, jsonrpc.NewNotification("log", []string{"typed"}).Prepare(server.URL).ExecuteNotification(server.Client()))
+	require.Equal(t, "typed", <-notified)
+}
+
+func TestHandlerEmptyBatch(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+
+	require.JSONEq(t,
+		`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		postRaw(t, server.URL, `[]`),
+	)
+}
+
+func TestHandlerRejectsOversizeBody(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler()
+	h.SetMaxRequestBytes(64)
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	body := `{"jsonrpc":"2.0","method":"add","params":[` + strings.Repeat("1,", 64) + `1],"id":1}`
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	require.JSONEq(t,
+		`{"jsonrpc":"2.0","result":3,"id":1}`,
+		postRaw(t, server.URL, `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`),
+	)
+}
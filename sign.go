@@ -0,0 +1,46 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/rotisserie/eris"
+)
+
+// WithRequestSigner lets sign authenticate every attempt of the call, e.g.
+// with an HMAC over the body and a timestamp header set on req. body is
+// exactly what is sent, after compression; it is empty for a GET. A
+// streamed body is buffered to be signed. An error from sign fails the
+// attempt before it is sent.
+func WithRequestSigner(sign func(body []byte, req *http.Request) error) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.signer = sign
+	}
+}
+
+// signRequest hands sign a copy of req, so the headers it sets do not leak
+// into the prepared request or other attempts.
+func signRequest(req *http.Request, sign func([]byte, *http.Request) error) (*http.Request, error) {
+	req = req.Clone(req.Context())
+
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+
+		if err != nil {
+			return nil, eris.Wrap(err, "read body to sign")
+		}
+
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := sign(body, req); err != nil {
+		return nil, eris.Wrap(err, "sign request")
+	}
+
+	return req, nil
+}
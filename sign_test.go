@@ -0,0 +1,137 @@
+package jsonrpc_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+var signingKey = []byte("secret")
+
+func hmacSign(body []byte, timestamp string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hmacSigner(body []byte, req *http.Request) error {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", hmacSign(body, timestamp))
+
+	return nil
+}
+
+// newSignatureServer fails the first failures attempts with 503 and answers
+// 401 to any request whose signature does not match the raw body.
+func newSignatureServer(failures int32) (*httptest.Server, *atomic.Int32) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		want := hmacSign(body, r.Header.Get("X-Timestamp"))
+		if !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Signature"))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if attempts.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}`))
+	}))
+
+	return server, &attempts
+}
+
+func TestRequestSignerSignsSentBytes(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newSignatureServer(0)
+	defer server.Close()
+
+	for name, opts := range map[string][]jsonrpc.PrepareOpt{
+		"plain":      nil,
+		"compressed": {jsonrpc.WithRequestCompression(0)},
+		"streamed":   {jsonrpc.WithStreamedBody()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := append([]jsonrpc.PrepareOpt{jsonrpc.WithRequestSigner(hmacSigner)}, opts...)
+
+			res, err := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{883189}).
+				Prepare(server.URL, opts...).
+				Execute(server.Client())
+			require.NoError(t, err)
+			require.Equal(t, "ok", *res)
+		})
+	}
+}
+
+func TestRequestSignerSignsEveryAttempt(t *testing.T) {
+	t.Parallel()
+
+	server, attempts := newSignatureServer(2)
+	defer server.Close()
+
+	var signed atomic.Int32
+
+	prepared := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{883189}).Prepare(
+		server.URL,
+		jsonrpc.WithRetry(3, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond)),
+		jsonrpc.WithRequestSigner(func(body []byte, req *http.Request) error {
+			signed.Add(1)
+
+			return hmacSigner(body, req)
+		}),
+	)
+
+	res, err := prepared.Execute(server.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.Equal(t, int32(3), attempts.Load())
+	require.Equal(t, int32(3), signed.Load())
+}
+
+func TestRequestSignerErrorFailsCall(t *testing.T) {
+	t.Parallel()
+
+	server, attempts := newSignatureServer(0)
+	defer server.Close()
+
+	errNoKey := errors.New("no signing key")
+
+	var signed atomic.Int32
+
+	_, err := jsonrpc.NewRequest[[]int, string]("getblockhash", []int{883189}).
+		Prepare(server.URL,
+			jsonrpc.WithRetry(3, jsonrpc.ExponentialBackoff(time.Millisecond, time.Millisecond)),
+			jsonrpc.WithRequestSigner(func([]byte, *http.Request) error {
+				signed.Add(1)
+
+				return errNoKey
+			}),
+		).
+		Execute(server.Client())
+	require.ErrorIs(t, err, errNoKey)
+	require.Zero(t, attempts.Load())
+
+	// a signer error is not retried
+	require.Equal(t, int32(1), signed.Load())
+}
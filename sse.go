@@ -0,0 +1,246 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/rotisserie/eris"
+)
+
+type Notification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type sseEnvelope struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      any             `json:"id,omitempty"`
+}
+
+// SSEStream reads JSON-RPC messages delivered as Server-Sent Events.
+// Responses are correlated by id through Await, server-initiated
+// notifications are delivered on Notifications. Notifications must be
+// drained, otherwise the reader blocks once the channel buffer is full.
+type SSEStream struct {
+	body          io.ReadCloser
+	codec         Codec
+	notifications chan Notification
+
+	mu      sync.Mutex
+	pending map[string]chan *RPCResponse[json.RawMessage]
+	arrived map[string]*RPCResponse[json.RawMessage]
+
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	done chan struct{}
+	err  error
+}
+
+// ExecuteSSE sends the prepared request and consumes the reply as a
+// text/event-stream until the server closes it or Close is called.
+func (rpc *praparedRPCRequest[Resp]) ExecuteSSE(client *http.Client, opts ...ExecuteOpt) (*SSEStream, error) {
+	if rpc.err != nil {
+		return nil, eris.Wrap(rpc.err, "execute prepared request")
+	}
+
+	// the prepared request is shared with other executions, so the header
+	// goes on a copy
+	req := rpc.internal
+	if req.Header.Get("Accept") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	req, err := rpc.beforeSend(req, rpc.method)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rpc.doRequest(req, client, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &SSEStream{
+		body:          resp.Body,
+		codec:         rpc.config.codec,
+		notifications: make(chan Notification, 64),
+		pending:       make(map[string]chan *RPCResponse[json.RawMessage]),
+		arrived:       make(map[string]*RPCResponse[json.RawMessage]),
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go stream.read()
+
+	return stream, nil
+}
+
+func (s *SSEStream) Notifications() <-chan Notification {
+	return s.notifications
+}
+
+// Await blocks until the response with the given id arrives, ctx is done or
+// the stream ends.
+func (s *SSEStream) Await(ctx context.Context, id any) (*RPCResponse[json.RawMessage], error) {
+	key := idKey(id)
+
+	s.mu.Lock()
+	if resp, ok := s.arrived[key]; ok {
+		delete(s.arrived, key)
+		s.mu.Unlock()
+		return resp, nil
+	}
+
+	ch := make(chan *RPCResponse[json.RawMessage], 1)
+	s.pending[key] = ch
+	s.mu.Unlock()
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+		return nil, eris.Wrap(ctx.Err(), "await sse response")
+	case <-s.done:
+		// the response may have been routed right before the stream ended
+		select {
+		case resp := <-ch:
+			return resp, nil
+		default:
+		}
+
+		if s.err != nil {
+			return nil, eris.Wrap(s.err, "await sse response")
+		}
+
+		return nil, eris.New("sse stream closed before response arrived")
+	}
+}
+
+// AwaitResult waits for the response with the given id and decodes its result.
+func AwaitResult[Resp any](ctx context.Context, s *SSEStream, id any) (*Resp, error) {
+	resp, err := s.Await(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result Resp
+	if err := s.codec.Unmarshal(resp.Result, &result); err != nil {
+		return nil, eris.Wrap(err, "decode response")
+	}
+
+	return &result, nil
+}
+
+// Err reports the error that terminated the stream, if any.
+func (s *SSEStream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+func (s *SSEStream) Close() error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	err := s.body.Close()
+	<-s.done
+
+	return err
+}
+
+func (s *SSEStream) read() {
+	defer close(s.done)
+	defer close(s.notifications)
+	defer func() { _ = s.body.Close() }()
+
+	reader := bufio.NewReaderSize(s.body, 64<<10)
+
+	var data bytes.Buffer
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err != io.EOF && !eris.Is(err, http.ErrBodyReadAfterClose) {
+				s.err = eris.Wrap(err, "read sse stream")
+			}
+			if data.Len() > 0 {
+				s.dispatch(data.Bytes())
+			}
+			return
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(line) == 0:
+			if data.Len() > 0 {
+				s.dispatch(data.Bytes())
+				data.Reset()
+			}
+		case line[0] == ':':
+			// comment / keep-alive
+		case bytes.HasPrefix(line, []byte("data:")):
+			value := bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+		}
+	}
+}
+
+func (s *SSEStream) dispatch(payload []byte) {
+	var msg sseEnvelope
+	if err := s.codec.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	if msg.ID == nil && msg.Method != "" {
+		select {
+		case s.notifications <- Notification{JSONRPC: msg.JSONRPC, Method: msg.Method, Params: msg.Params}:
+		case <-s.closing:
+		}
+		return
+	}
+
+	resp := &RPCResponse[json.RawMessage]{
+		JSONRPC: msg.JSONRPC,
+		Result:  msg.Result,
+		Error:   msg.Error,
+		ID:      msg.ID,
+	}
+
+	key := idKey(msg.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch, ok := s.pending[key]; ok {
+		delete(s.pending, key)
+		ch <- resp
+		return
+	}
+
+	s.arrived[key] = resp
+}
@@ -0,0 +1,172 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteSSECorrelatesResponsesAndRoutesNotifications(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[[]string, string](
+		"eth_subscribe",
+		[]string{"newHeads"},
+		jsonrpc.WithRPCid[[]string, string](7),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"eth_subscription\",\n")
+		fmt.Fprint(w, "data: \"params\":{\"subscription\":\"0xab\",\"result\":1}}\n\n")
+		flusher.Flush()
+
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"result\":\"0xab\",\"id\":7}\r\n\r\n")
+		flusher.Flush()
+
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"eth_subscription\",\"params\":{\"subscription\":\"0xab\",\"result\":2}}\n\n")
+		flusher.Flush()
+	}))
+	defer server.Close()
+
+	stream, err := req.Prepare(server.URL).ExecuteSSE(server.Client())
+	require.NoError(t, err)
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := jsonrpc.AwaitResult[string](ctx, stream, 7)
+	require.NoError(t, err)
+	require.Equal(t, "0xab", *result)
+
+	var notifications []jsonrpc.Notification
+	for n := range stream.Notifications() {
+		notifications = append(notifications, n)
+	}
+
+	require.Len(t, notifications, 2)
+	require.Equal(t, "eth_subscription", notifications[0].Method)
+	require.JSONEq(t, `{"subscription":"0xab","result":1}`, string(notifications[0].Params))
+	require.JSONEq(t, `{"subscription":"0xab","result":2}`, string(notifications[1].Params))
+	require.NoError(t, stream.Err())
+}
+
+func TestExecuteSSEAwaitAfterStreamEnds(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string]("missing", struct{}{}, jsonrpc.WithRPCid[struct{}, string]("a"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"error\":{\"code\":-32601,\"message\":\"nope\"},\"id\":\"a\"}\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := req.Prepare(server.URL).ExecuteSSE(server.Client())
+	require.NoError(t, err)
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = jsonrpc.AwaitResult[string](ctx, stream, "a")
+	var rpcErr *jsonrpc.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32601, rpcErr.Code)
+
+	_, err = stream.Await(ctx, "b")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "sse stream closed")
+}
+
+func TestExecuteSSECloseWithUndrainedNotifications(t *testing.T) {
+	t.Parallel()
+
+	req := jsonrpc.NewRequest[struct{}, string]("flood", struct{}{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := range 256 {
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"tick\",\"params\":[%d]}\n\n", i)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	stream, err := req.Prepare(server.URL).ExecuteSSE(server.Client())
+	require.NoError(t, err)
+
+	closed := make(chan struct{})
+	go func() {
+		_ = stream.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("close blocked on undrained notifications")
+	}
+}
+
+func TestExecuteSSEKeepsAcceptOverride(t *testing.T) {
+	t.Parallel()
+
+	accepts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts <- r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	stream, err := jsonrpc.NewRequest[struct{}, string]("subscribe", struct{}{}).
+		Prepare(server.URL, jsonrpc.WithHeader("Accept", "application/vnd.node+event-stream")).
+		ExecuteSSE(server.Client())
+	require.NoError(t, err)
+	defer stream.Close()
+
+	require.Equal(t, "application/vnd.node+event-stream", <-accepts)
+}
+
+func TestExecuteSSEDoesNotLeakAccept(t *testing.T) {
+	t.Parallel()
+
+	accepts := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		accepts <- accept
+
+		if accept == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			return
+		}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[struct{}, string]("subscribe", struct{}{}).Prepare(server.URL)
+
+	stream, err := prepared.ExecuteSSE(server.Client())
+	require.NoError(t, err)
+	defer stream.Close()
+
+	require.Equal(t, "text/event-stream", <-accepts)
+
+	_, err = prepared.Execute(server.Client())
+	require.NoError(t, err)
+	require.NotEqual(t, "text/event-stream", <-accepts)
+}
@@ -0,0 +1,175 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/rotisserie/eris"
+)
+
+// ExecuteEach sends the request and calls fn with every element of the
+// array result as it is read from the response, so a huge result, e.g. all
+// the transactions of a block, is never held in memory as a whole. Only a
+// top-level array result is supported; a null result calls fn never. An
+// error from fn stops reading and is returned.
+//
+// Elements are decoded with the request's codec one at a time. The
+// response preprocessor, WithStrictDecode and WithValidate do not apply.
+func ExecuteEach[Elem any, Resp ~[]Elem](rpc *praparedRPCRequest[Resp], client *http.Client, fn func(Elem) error, opts ...ExecuteOpt) error {
+	if rpc.err != nil {
+		return eris.Wrap(rpc.err, "execute prepared request")
+	}
+
+	result := RPCResponse[Resp]{
+		each: func(raw []byte) error {
+			var elem Elem
+			if err := rpc.config.codec.Unmarshal(raw, &elem); err != nil {
+				return eris.Wrap(err, "decode result element")
+			}
+
+			return fn(elem)
+		},
+	}
+
+	if _, err := rpc.roundTrip(client, opts, &result); err != nil {
+		return rpc.wrap(err)
+	}
+
+	if result.Error != nil {
+		return rpc.wrap(result.Error)
+	}
+
+	return nil
+}
+
+// decodeEach walks the response envelope token by token and hands every
+// element of the result to result.each without decoding the whole body.
+func (rpc *praparedRPCRequest[Resp]) decodeEach(resp *http.Response, result *RPCResponse[Resp]) error {
+	body, _, err := decodeContent(resp)
+	if err != nil {
+		return eris.Wrap(err, "read response")
+	}
+
+	var limited *io.LimitedReader
+	if limit := rpc.config.maxResponseBytes; limit > 0 {
+		limited = &io.LimitedReader{R: body, N: limit + 1}
+		body = limited
+	}
+
+	err = decodeEnvelope(json.NewDecoder(body), result)
+	if err != nil && limited != nil && limited.N <= 0 {
+		return eris.Wrapf(ErrResponseTooLarge, "read response: limit %d bytes", rpc.config.maxResponseBytes)
+	}
+
+	return err
+}
+
+func decodeEnvelope[Resp any](dec *json.Decoder, result *RPCResponse[Resp]) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	streamed := false
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return eris.Wrap(err, "decode response")
+		}
+
+		switch key {
+		case "jsonrpc":
+			err = dec.Decode(&result.JSONRPC)
+		case "id":
+			err = dec.Decode(&result.ID)
+		case "error":
+			err = dec.Decode(&result.Error)
+		case "result":
+			// an error sent first makes any result ambiguous; one sent
+			// after it is caught once the envelope is complete
+			if result.Error != nil {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return eris.Wrap(err, "decode response")
+				}
+
+				if string(raw) != "null" {
+					return &AmbiguousResponseError{ID: result.ID, Err: result.Error}
+				}
+
+				continue
+			}
+
+			streamed, err = streamArray(dec, result.each)
+			if err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+
+		if err != nil {
+			return eris.Wrap(err, "decode response")
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	if result.Error != nil && streamed {
+		return &AmbiguousResponseError{ID: result.ID, Err: result.Error}
+	}
+
+	result.null = !streamed
+
+	return nil
+}
+
+// streamArray reports whether the result was an array, null being skipped.
+func streamArray(dec *json.Decoder, each func([]byte) error) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, eris.Wrap(err, "decode response result")
+	}
+
+	if tok == nil {
+		return false, nil
+	}
+
+	if tok != json.Delim('[') {
+		return false, eris.Errorf("decode response result: expected an array, got %v", tok)
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return false, eris.Wrap(err, "decode response result")
+		}
+
+		if err := each(raw); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return false, eris.Wrap(err, "decode response result")
+	}
+
+	return true, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return eris.Wrap(err, "decode response")
+	}
+
+	if tok != delim {
+		return eris.Errorf("decode response: expected %v, got %v", delim, tok)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package jsonrpc_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type streamedTx struct {
+	TxID string `json:"txid"`
+	Size int    `json:"size"`
+}
+
+func newStaticServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestExecuteEachStreamsArrayResult(t *testing.T) {
+	t.Parallel()
+
+	const count = 10_000
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"extra":{"ignored":[1,2]},"result":[`)
+		for i := range count {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+
+			fmt.Fprintf(w, `{"txid":"tx-%d","size":%d}`, i, i)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[[]any, []streamedTx]("getblock", []any{"hash", 2}).Prepare(server.URL)
+
+	var seen int
+
+	err := jsonrpc.ExecuteEach(prepared, server.Client(), func(tx streamedTx) error {
+		require.Equal(t, streamedTx{TxID: fmt.Sprintf("tx-%d", seen), Size: seen}, tx)
+		seen++
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, count, seen)
+}
+
+func TestExecuteEachNullResult(t *testing.T) {
+	t.Parallel()
+
+	server := newStaticServer(`{"jsonrpc":"2.0","id":1,"result":null}`)
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[[]any, []streamedTx]("getblock", nil).Prepare(server.URL)
+
+	err := jsonrpc.ExecuteEach(prepared, server.Client(), func(streamedTx) error {
+		t.Fatal("no element expected")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestExecuteEachCallbackErrorStops(t *testing.T) {
+	t.Parallel()
+
+	server := newStaticServer(`{"jsonrpc":"2.0","id":1,"result":[{"txid":"a"},{"txid":"b"},{"txid":"c"}]}`)
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[[]any, []streamedTx]("getblock", nil).Prepare(server.URL)
+
+	errStop := errors.New("stop")
+
+	var seen []string
+
+	err := jsonrpc.ExecuteEach(prepared, server.Client(), func(tx streamedTx) error {
+		seen = append(seen, tx.TxID)
+		if tx.TxID == "b" {
+			return errStop
+		}
+
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, []string{"a", "b"}, seen)
+}
+
+func TestExecuteEachErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, err error)
+	}{
+		{
+			name: "rpc error",
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-8,"message":"Block not found"}}`,
+			check: func(t *testing.T, err error) {
+				var rpcErr *jsonrpc.RPCError
+				require.ErrorAs(t, err, &rpcErr)
+				require.Equal(t, -8, rpcErr.Code)
+			},
+		},
+		{
+			name: "error before result",
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-8,"message":"x"},"result":[{"txid":"a"}]}`,
+			check: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, jsonrpc.ErrAmbiguousResponse)
+			},
+		},
+		{
+			name: "error after result",
+			body: `{"jsonrpc":"2.0","id":1,"result":[],"error":{"code":-8,"message":"x"}}`,
+			check: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, jsonrpc.ErrAmbiguousResponse)
+			},
+		},
+		{
+			name: "not an array",
+			body: `{"jsonrpc":"2.0","id":1,"result":{"txid":"a"}}`,
+			check: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "expected an array")
+			},
+		},
+		{
+			name: "truncated",
+			body: `{"jsonrpc":"2.0","id":1,"result":[{"txid":"a"},`,
+			check: func(t *testing.T, err error) {
+				require.ErrorContains(t, err, "decode response")
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newStaticServer(tc.body)
+			defer server.Close()
+
+			prepared := jsonrpc.NewRequest[[]any, []streamedTx]("getblock", nil).Prepare(server.URL)
+
+			tc.check(t, jsonrpc.ExecuteEach(prepared, server.Client(), func(streamedTx) error { return nil }))
+		})
+	}
+}
+
+func TestExecuteEachMaxResponseBytes(t *testing.T) {
+	t.Parallel()
+
+	server := newStaticServer(`{"jsonrpc":"2.0","id":1,"result":["` + strings.Repeat("a", 4<<10) + `"]}`)
+	defer server.Close()
+
+	prepared := jsonrpc.NewRequest[[]any, []string]("getblock", nil).
+		Prepare(server.URL, jsonrpc.WithMaxResponseBytes(1<<10))
+
+	err := jsonrpc.ExecuteEach(prepared, server.Client(), func(string) error { return nil })
+	require.ErrorIs(t, err, jsonrpc.ErrResponseTooLarge)
+}
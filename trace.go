@@ -0,0 +1,45 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http/httptrace"
+)
+
+// Tracer starts a span around every executed request. It is deliberately
+// small so an OpenTelemetry tracer can be adapted to it without the core
+// depending on otel. The returned context is used for the HTTP request, so
+// trace propagation in the transport sees the new span.
+type Tracer interface {
+	Start(ctx context.Context, method string) (context.Context, Span)
+}
+
+// Span receives attributes named after the OpenTelemetry semantic
+// conventions. End is called once with the call's error, nil on success;
+// JSON-RPC errors are passed as *RPCError.
+type Span interface {
+	SetAttribute(key string, value any)
+	End(err error)
+}
+
+const (
+	AttrRPCSystem      = "rpc.system"
+	AttrRPCMethod      = "rpc.method"
+	AttrRPCRequestID   = "rpc.jsonrpc.request_id"
+	AttrRPCErrorCode   = "rpc.jsonrpc.error_code"
+	AttrHTTPStatusCode = "http.response.status_code"
+)
+
+func WithTracer(t Tracer) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.tracer = t
+	}
+}
+
+// WithClientTrace attaches trace to the context of every HTTP attempt, to
+// time DNS, connect, TLS and first byte. It composes with a trace already
+// on the caller's context, whose hooks run too.
+func WithClientTrace(trace *httptrace.ClientTrace) PrepareOpt {
+	return func(c *prepareConfig) {
+		c.clientTrace = trace
+	}
+}
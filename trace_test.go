@@ -0,0 +1,140 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *recordingSpan) SetAttribute(key string, value any) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, method string) (context.Context, jsonrpc.Span) {
+	span := &recordingSpan{name: method, attrs: map[string]any{}}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestExecuteWithTracer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"no such method"},"id":"7"}`)
+			return
+		}
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":"7"}`)
+	}))
+	defer server.Close()
+
+	tracer := &recordingTracer{}
+
+	// the span context must reach the transport for propagation
+	var propagated []any
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		propagated = append(propagated, r.Context().Value(spanKey{}))
+		return server.Client().Transport.RoundTrip(r)
+	})}
+
+	req := jsonrpc.NewRequest[struct{}, string]("getblock", struct{}{}, jsonrpc.WithRPCid[struct{}, string]("7"))
+
+	res, err := req.Prepare(server.URL, jsonrpc.WithTracer(tracer)).Execute(client)
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	_, err = req.Prepare(server.URL+"/error", jsonrpc.WithTracer(tracer)).Execute(client)
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+
+	require.Len(t, tracer.spans, 2)
+
+	ok := tracer.spans[0]
+	require.True(t, ok.ended)
+	require.NoError(t, ok.err)
+	require.Equal(t, "getblock", ok.name)
+	require.Equal(t, map[string]any{
+		jsonrpc.AttrRPCSystem:      "jsonrpc",
+		jsonrpc.AttrRPCMethod:      "getblock",
+		jsonrpc.AttrRPCRequestID:   "7",
+		jsonrpc.AttrHTTPStatusCode: http.StatusOK,
+	}, ok.attrs)
+
+	failed := tracer.spans[1]
+	require.True(t, failed.ended)
+	require.ErrorIs(t, failed.err, jsonrpc.ErrMethodNotFound)
+	require.Equal(t, jsonrpc.CodeMethodNotFound, failed.attrs[jsonrpc.AttrRPCErrorCode])
+
+	require.Equal(t, []any{ok, failed}, propagated)
+}
+
+func TestExecuteWithClientTrace(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"ok","id":1}`)
+	}))
+	defer server.Close()
+
+	var (
+		mu     sync.Mutex
+		phases []string
+	)
+	record := func(phase string) {
+		mu.Lock()
+		phases = append(phases, phase)
+		mu.Unlock()
+	}
+
+	// a trace on the caller's context keeps working alongside
+	callerTrace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { record("wrote") },
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), callerTrace)
+
+	res, err := jsonrpc.NewRequest[struct{}, string]("ping", struct{}{}, jsonrpc.WithRPCid[struct{}, string](1)).
+		Prepare(server.URL,
+			jsonrpc.WithContext(ctx),
+			jsonrpc.WithClientTrace(&httptrace.ClientTrace{
+				ConnectDone:          func(string, string, error) { record("connect") },
+				GotConn:              func(httptrace.GotConnInfo) { record("conn") },
+				GotFirstResponseByte: func() { record("first byte") },
+			}),
+		).
+		Execute(&http.Client{Transport: &http.Transport{}})
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"connect", "conn", "wrote", "first byte"}, phases)
+}
@@ -0,0 +1,282 @@
+package jsonrpc
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// TransportConfig holds the tunables of the transport built by NewTransport.
+// Start from DefaultTransportConfig, which is tuned for multi-MB responses,
+// and adjust the fields that matter for the workload.
+type TransportConfig struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ExpectContinueTimeout time.Duration
+	ReadBufferSize        int
+	WriteBufferSize       int
+	DisableCompression    bool
+	TLSSessionCacheSize   int
+
+	// Proxy picks the proxy for a request; nil means the environment's
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY settings.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout: 5 * time.Second,
+		KeepAlive:   30 * time.Second,
+
+		// Large response tuning: allow many idle conns but cap concurrency
+		MaxIdleConns:        4_096,
+		MaxIdleConnsPerHost: 1_024,
+		MaxConnsPerHost:     512, // cap to bound memory while handling many large bodies
+
+		// Keep generous idle timeout for reuse; large responses take longer
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 250 * time.Millisecond,
+
+		// Increase per-connection IO buffers to reduce syscalls for large bodies
+		// Defaults are 4KB; bump to 64KB (common page multiple).
+		ReadBufferSize:  64 << 10,
+		WriteBufferSize: 64 << 10,
+
+		// Enable compression to save bandwidth for multi-MB JSON; CPU tradeoff
+		DisableCompression: false,
+
+		// TLS session cache to lower handshake CPU when many connections exist
+		TLSSessionCacheSize: 4096,
+	}
+}
+
+func NewTransport(cfg TransportConfig) *http.Transport {
+	proxy := cfg.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: cfg.KeepAlive,
+		}).DialContext,
+		ForceAttemptHTTP2: true,
+
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
+
+		DisableCompression: cfg.DisableCompression,
+
+		ReadBufferSize:  cfg.ReadBufferSize,
+		WriteBufferSize: cfg.WriteBufferSize,
+
+		TLSClientConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ClientSessionCache: tls.NewLRUClientSessionCache(cfg.TLSSessionCacheSize),
+		},
+
+		// HTTP/2: raise concurrent streams per connection for multiplexing large responses
+		// (Go picks defaults; env GODEBUG may tune; leaving default to avoid incompat issues)
+	}
+}
+
+// WithTransportConfig gives the client its own transport built from cfg.
+func WithTransportConfig(cfg TransportConfig) ClientOption {
+	return WithTransport(NewTransport(cfg))
+}
+
+// Middleware wraps a RoundTripper, e.g. to refresh auth or log requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// ChainTransports wraps base in middleware, the first one being the
+// outermost. A nil base is the package's tuned default transport, so its
+// connection pool and buffer settings are kept.
+func ChainTransports(base http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = defaultTransport
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		base = middleware[i](base)
+	}
+
+	return base
+}
+
+// WithTransport sends the client's calls through rt, keeping the rest of the
+// HTTP client configuration. Build rt with ChainTransports to keep the tuned
+// default transport underneath.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		cli := *c.httpClient
+		cli.Transport = rt
+		c.httpClient = &cli
+	}
+}
+
+// withHTTPTransport gives the client a copy of the *http.Transport it sends
+// through and lets edit adjust it. A RoundTripper of another type, e.g. a
+// middleware chain, cannot be edited, so every call fails instead of
+// silently going out without option.
+func withHTTPTransport(option string, edit func(*http.Transport)) ClientOption {
+	return func(c *Client) {
+		rt := c.httpClient.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+
+		base, ok := rt.(*http.Transport)
+		if !ok {
+			err := eris.Errorf("apply %s: client transport is %T, not *http.Transport", option, rt)
+			c.opts = append(c.opts, func(cfg *prepareConfig) {
+				cfg.optionErr = err
+			})
+
+			return
+		}
+
+		transport := base.Clone()
+		edit(transport)
+
+		WithTransport(transport)(c)
+	}
+}
+
+// WithDialer opens the client's connections with d, e.g. to tune its
+// KeepAliveConfig for a polling loop. Go already disables Nagle's algorithm
+// (TCP_NODELAY) on every TCP connection; set d.Control to change socket
+// options further.
+func WithDialer(d *net.Dialer) ClientOption {
+	return withHTTPTransport("WithDialer", func(t *http.Transport) {
+		t.DialContext = d.DialContext
+	})
+}
+
+// WithDisableKeepAlives opens a fresh connection for every call and closes
+// it afterwards, e.g. in serverless environments where NAT drops idle
+// connections. It trades the pooling of the default tuning for that.
+func WithDisableKeepAlives() ClientOption {
+	return withHTTPTransport("WithDisableKeepAlives", func(t *http.Transport) {
+		t.DisableKeepAlives = true
+	})
+}
+
+// WithProxy routes the client's calls through proxyURL regardless of the
+// proxy environment variables. An invalid URL fails every call, as does a
+// client sending through middleware: set TransportConfig.Proxy on the
+// wrapped transport instead.
+func WithProxy(proxyURL string) ClientOption {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		err = eris.Wrap(err, "parse proxy url")
+
+		return WithProxyFunc(func(*http.Request) (*url.URL, error) {
+			return nil, err
+		})
+	}
+
+	return WithProxyFunc(http.ProxyURL(u))
+}
+
+// WithProxyFunc lets fn pick the proxy per request, like http.Transport's
+// Proxy field; a nil URL means a direct connection.
+func WithProxyFunc(fn func(*http.Request) (*url.URL, error)) ClientOption {
+	return withHTTPTransport("WithProxyFunc", func(t *http.Transport) {
+		t.Proxy = fn
+	})
+}
+
+// WithTLSConfig verifies servers with cfg, e.g. to pin a private CA through
+// RootCAs. The transport's minimum version and client certificates are kept
+// unless cfg sets its own.
+// Without a ClientSessionCache in cfg the client gets a cache of its own, as
+// sessions verified under other roots must not be resumed.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return withHTTPTransport("WithTLSConfig", func(t *http.Transport) {
+		tlsConfig := cfg.Clone()
+
+		if base := t.TLSClientConfig; base != nil {
+			if tlsConfig.MinVersion == 0 {
+				tlsConfig.MinVersion = base.MinVersion
+			}
+
+			if len(tlsConfig.Certificates) == 0 {
+				tlsConfig.Certificates = base.Certificates
+			}
+		}
+
+		if tlsConfig.ClientSessionCache == nil {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(DefaultTransportConfig().TLSSessionCacheSize)
+		}
+
+		t.TLSClientConfig = tlsConfig
+	})
+}
+
+// WithClientCertificate presents cert to servers that require mutual TLS. It
+// composes with WithTLSConfig in either order. The client gets a session
+// cache of its own, as a session authenticated with cert must not be
+// resumed by clients without it.
+func WithClientCertificate(cert tls.Certificate) ClientOption {
+	return withHTTPTransport("WithClientCertificate", func(t *http.Transport) {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if t.TLSClientConfig != nil {
+			tlsConfig = t.TLSClientConfig.Clone()
+		}
+
+		tlsConfig.Certificates = append(slices.Clip(tlsConfig.Certificates), cert)
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(DefaultTransportConfig().TLSSessionCacheSize)
+		t.TLSClientConfig = tlsConfig
+	})
+}
+
+// WithInsecureSkipVerify accepts any server certificate. It is meant for
+// local development against self-signed nodes only: it leaves the
+// connection open to interception, so never use it in production. The
+// client gets a session cache of its own, so verifying clients never resume
+// its unverified sessions.
+func WithInsecureSkipVerify() ClientOption {
+	return withHTTPTransport("WithInsecureSkipVerify", func(t *http.Transport) {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if t.TLSClientConfig != nil {
+			tlsConfig = t.TLSClientConfig.Clone()
+		}
+
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(DefaultTransportConfig().TLSSessionCacheSize)
+		t.TLSClientConfig = tlsConfig
+	})
+}
+
+// WithH2C speaks HTTP/2 with prior knowledge to http:// endpoints, e.g. local
+// nodes serving cleartext h2c, so concurrent calls are multiplexed over a
+// single connection. net/http only does so with HTTP/1 disabled, so the
+// client speaks HTTP/2 alone: https:// endpoints must negotiate it over TLS,
+// and any endpoint that only speaks HTTP/1 fails every call.
+func WithH2C() ClientOption {
+	return withHTTPTransport("WithH2C", func(t *http.Transport) {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+
+		t.Protocols = protocols
+	})
+}
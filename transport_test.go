@@ -0,0 +1,564 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func tagMiddleware(tag string, order *[]string) jsonrpc.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			*order = append(*order, tag)
+			r = r.Clone(r.Context())
+			r.Header.Add("X-Middleware", tag)
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func TestClientWithChainedTransports(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	var order []string
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(jsonrpc.ChainTransports(
+		nil,
+		tagMiddleware("auth", &order),
+		tagMiddleware("log", &order),
+	)))
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "ok", res)
+
+	require.Equal(t, []string{"auth", "log"}, order)
+	require.Equal(t, []string{"auth", "log"}, (<-calls).Header.Values("X-Middleware"))
+}
+
+func TestClientWithTransportKeepsDefaultClient(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	var order []string
+
+	// replacing the transport of one client must not affect the shared default
+	_ = jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(tagMiddleware("other", &order)(http.DefaultTransport)))
+
+	res, err := jsonrpc.NewRequest[struct{}, string]("getblockcount", struct{}{}).
+		Prepare(server.URL).
+		Execute(nil)
+	require.NoError(t, err)
+	require.Equal(t, "ok", *res)
+	require.Empty(t, order)
+	require.Empty(t, (<-calls).Header.Values("X-Middleware"))
+}
+
+func TestNewTransportAppliesConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := jsonrpc.DefaultTransportConfig()
+	cfg.MaxConnsPerHost = 8
+	cfg.ReadBufferSize = 4 << 10
+
+	transport := jsonrpc.NewTransport(cfg)
+	require.Equal(t, 8, transport.MaxConnsPerHost)
+	require.Equal(t, 4<<10, transport.ReadBufferSize)
+	require.Equal(t, 1_024, transport.MaxIdleConnsPerHost)
+	require.Equal(t, 64<<10, transport.WriteBufferSize)
+	require.True(t, transport.ForceAttemptHTTP2)
+}
+
+func TestClientWithTransportConfig(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	cfg := jsonrpc.DefaultTransportConfig()
+	cfg.MaxConnsPerHost = 1
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTransportConfig(cfg))
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "ok", res)
+}
+
+func TestClientWithDialer(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 2)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	var dials atomic.Int32
+
+	dialer := &net.Dialer{
+		Timeout:         time.Second,
+		KeepAliveConfig: net.KeepAliveConfig{Enable: true, Idle: 5 * time.Second},
+		Control: func(network, address string, _ syscall.RawConn) error {
+			dials.Add(1)
+			return nil
+		},
+	}
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(&http.Transport{}), jsonrpc.WithDialer(dialer))
+
+	var res string
+	for range 2 {
+		require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+		require.Equal(t, "ok", res)
+	}
+
+	// the second call reuses the kept-alive connection
+	require.Equal(t, int32(1), dials.Load())
+}
+
+func TestClientWithDisableKeepAlives(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 2)
+	server := newClientServer(t, calls, `"ok"`)
+	defer server.Close()
+
+	var dials atomic.Int32
+
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			dials.Add(1)
+			return nil
+		},
+	}
+
+	client := jsonrpc.NewClient(server.URL,
+		jsonrpc.WithTransport(&http.Transport{}),
+		jsonrpc.WithDialer(dialer),
+		jsonrpc.WithDisableKeepAlives(),
+	)
+
+	var res string
+	for range 2 {
+		require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+		require.Equal(t, "ok", res)
+	}
+
+	require.Equal(t, int32(2), dials.Load())
+}
+
+func TestClientWithProxy(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	proxy := newClientServer(t, calls, `"proxied"`)
+	defer proxy.Close()
+
+	// the node host does not resolve, so only the proxy can answer
+	client := jsonrpc.NewClient("http://node.invalid/rpc", jsonrpc.WithProxy(proxy.URL))
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "proxied", res)
+	require.Len(t, calls, 1)
+}
+
+func TestClientWithProxyFunc(t *testing.T) {
+	t.Parallel()
+
+	calls := make(chan clientCall, 1)
+	server := newClientServer(t, calls, `"direct"`)
+	defer server.Close()
+
+	var asked []string
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithProxyFunc(func(r *http.Request) (*url.URL, error) {
+		asked = append(asked, r.URL.Host)
+		return nil, nil
+	}))
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "direct", res)
+	require.Len(t, asked, 1)
+}
+
+func TestClientWithInvalidProxy(t *testing.T) {
+	t.Parallel()
+
+	client := jsonrpc.NewClient("http://node.invalid/rpc", jsonrpc.WithProxy("://no-scheme"))
+
+	err := client.Call(context.Background(), "getblockcount", nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parse proxy url")
+}
+
+func TestClientTransportOptionsBehindMiddlewareFailCalls(t *testing.T) {
+	t.Parallel()
+
+	options := map[string]jsonrpc.ClientOption{
+		"WithProxyFunc":          jsonrpc.WithProxy("http://proxy.invalid"),
+		"WithTLSConfig":          jsonrpc.WithTLSConfig(&tls.Config{}),
+		"WithClientCertificate":  jsonrpc.WithClientCertificate(tls.Certificate{}),
+		"WithInsecureSkipVerify": jsonrpc.WithInsecureSkipVerify(),
+		"WithDialer":             jsonrpc.WithDialer(&net.Dialer{}),
+		"WithH2C":                jsonrpc.WithH2C(),
+		"WithDisableKeepAlives":  jsonrpc.WithDisableKeepAlives(),
+	}
+
+	for name, option := range options {
+		calls := make(chan clientCall, 1)
+		server := newClientServer(t, calls, `"ok"`)
+
+		var order []string
+
+		client := jsonrpc.NewClient(server.URL,
+			jsonrpc.WithTransport(jsonrpc.ChainTransports(nil, tagMiddleware("log", &order))),
+			option,
+		)
+
+		err := client.Call(context.Background(), "getblockcount", nil, nil)
+		require.ErrorContains(t, err, "apply "+name, name)
+		require.Empty(t, calls, name)
+
+		server.Close()
+	}
+}
+
+func newTLSClientServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"secure","id":null}`)
+	}))
+}
+
+func TestClientTLSSelfSignedIsRejected(t *testing.T) {
+	t.Parallel()
+
+	server := newTLSClientServer(t)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL)
+
+	var res string
+	require.Error(t, client.Call(context.Background(), "getblockcount", nil, &res))
+}
+
+func TestClientWithInsecureSkipVerify(t *testing.T) {
+	t.Parallel()
+
+	server := newTLSClientServer(t)
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithInsecureSkipVerify())
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "secure", res)
+}
+
+func TestClientWithInsecureSkipVerifyDoesNotShareSessions(t *testing.T) {
+	t.Parallel()
+
+	server := newTLSClientServer(t)
+	defer server.Close()
+
+	shared := jsonrpc.NewTransport(jsonrpc.DefaultTransportConfig())
+	cache := shared.TLSClientConfig.ClientSessionCache
+
+	insecure := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(shared), jsonrpc.WithInsecureSkipVerify())
+
+	var res string
+	require.NoError(t, insecure.Call(context.Background(), "getblockcount", nil, &res))
+
+	// the unverified session stays out of the cache verifying clients use
+	host, _, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	session, ok := cache.Get(host)
+	require.False(t, ok)
+	require.Nil(t, session)
+}
+
+func TestClientWithTLSConfigPinsCA(t *testing.T) {
+	t.Parallel()
+
+	server := newTLSClientServer(t)
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTLSConfig(&tls.Config{RootCAs: pool}))
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "secure", res)
+
+	// a pool without the server's CA rejects it
+	pinned := jsonrpc.NewClient(server.URL, jsonrpc.WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()}))
+	require.Error(t, pinned.Call(context.Background(), "getblockcount", nil, &res))
+}
+
+func TestClientHostAndServerNameAreIndependent(t *testing.T) {
+	t.Parallel()
+
+	type seen struct{ host, serverName string }
+
+	calls := make(chan seen, 1)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- seen{host: r.Host, serverName: r.TLS.ServerName}
+
+		fmt.Fprint(w, `{"jsonrpc":"2.0","result":"secure","id":null}`)
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	// the test certificate is issued for example.com, the address is an IP
+	client := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "example.com"}),
+		jsonrpc.WithDefaultOptions(jsonrpc.WithHost("rpc.internal")),
+	)
+
+	var res string
+	require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, seen{host: "rpc.internal", serverName: "example.com"}, <-calls)
+
+	// the certificate is verified against ServerName, never the Host header
+	hostOnly := jsonrpc.NewClient(
+		server.URL,
+		jsonrpc.WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "rpc.internal"}),
+		jsonrpc.WithDefaultOptions(jsonrpc.WithHost("example.com")),
+	)
+	require.Error(t, hostOnly.Call(context.Background(), "getblockcount", nil, &res))
+}
+
+func newClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jsonrpc-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestClientWithClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	cert, leaf := newClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%q,"id":null}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	var res string
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTLSConfig(&tls.Config{RootCAs: roots}))
+	require.Error(t, client.Call(context.Background(), "getblockcount", nil, &res))
+
+	for _, opts := range [][]jsonrpc.ClientOption{
+		{jsonrpc.WithTLSConfig(&tls.Config{RootCAs: roots}), jsonrpc.WithClientCertificate(cert)},
+		{jsonrpc.WithClientCertificate(cert), jsonrpc.WithTLSConfig(&tls.Config{RootCAs: roots})},
+	} {
+		client := jsonrpc.NewClient(server.URL, opts...)
+
+		res = ""
+		require.NoError(t, client.Call(context.Background(), "getblockcount", nil, &res))
+		require.Equal(t, "jsonrpc-client", res)
+	}
+}
+
+func TestClientWithClientCertificateDoesNotShareSessions(t *testing.T) {
+	t.Parallel()
+
+	cert, leaf := newClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := "anonymous"
+		if len(r.TLS.PeerCertificates) > 0 {
+			identity = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%q,"id":null}`, identity)
+	}))
+	server.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// both clients start from one transport, and so from its session cache
+	shared := jsonrpc.NewTransport(jsonrpc.DefaultTransportConfig())
+	shared.TLSClientConfig.RootCAs = x509.NewCertPool()
+	shared.TLSClientConfig.RootCAs.AddCert(server.Certificate())
+
+	var res string
+
+	privileged := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(shared), jsonrpc.WithClientCertificate(cert))
+	require.NoError(t, privileged.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "jsonrpc-client", res)
+
+	anonymous := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(shared))
+	require.NoError(t, anonymous.Call(context.Background(), "getblockcount", nil, &res))
+	require.Equal(t, "anonymous", res)
+}
+
+func TestClientWithH2CMultiplexes(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		remotes = map[string]struct{}{}
+	)
+
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 8)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "h2c required", http.StatusHTTPVersionNotSupported)
+
+			return
+		}
+
+		mu.Lock()
+		remotes[r.RemoteAddr] = struct{}{}
+		mu.Unlock()
+
+		arrived <- struct{}{}
+		<-release
+
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"ok"}`))
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client := jsonrpc.NewClient(server.URL, jsonrpc.WithTransport(&http.Transport{}), jsonrpc.WithH2C())
+
+	const calls = 8
+
+	errs := make(chan error, calls)
+
+	for range calls {
+		go func() {
+			var res string
+			errs <- client.Call(context.Background(), "getblock", nil, &res)
+		}()
+	}
+
+	// every call is in flight at once before any of them is answered
+	for range calls {
+		<-arrived
+	}
+
+	close(release)
+
+	for range calls {
+		require.NoError(t, <-errs)
+	}
+
+	require.Len(t, remotes, 1)
+}
+
+func TestClientWithH2COverTLS(t *testing.T) {
+	t.Parallel()
+
+	newServer := func(h2 bool) *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%q}`, r.Proto)
+		}))
+		server.EnableHTTP2 = h2
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+
+		return server
+	}
+
+	h2 := newServer(true)
+	defer h2.Close()
+
+	client := jsonrpc.NewClient(h2.URL, jsonrpc.WithHTTPClient(h2.Client()), jsonrpc.WithH2C())
+
+	var proto string
+	require.NoError(t, client.Call(context.Background(), "getblock", nil, &proto))
+	require.Equal(t, "HTTP/2.0", proto)
+
+	// HTTP/1 is disabled along with it
+	h1 := newServer(false)
+	defer h1.Close()
+
+	client = jsonrpc.NewClient(h1.URL, jsonrpc.WithHTTPClient(h1.Client()), jsonrpc.WithH2C())
+	require.Error(t, client.Call(context.Background(), "getblock", nil, &proto))
+}
@@ -0,0 +1,517 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WSClient speaks JSON-RPC over a single persistent WebSocket connection.
+// Calls may be in flight concurrently and are matched to responses by id;
+// server-initiated notifications, e.g. eth_subscription, are delivered on
+// Notifications, which must be drained or the reader blocks once its buffer
+// is full.
+type WSClient struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	codec         Codec
+	notifications chan Notification
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *RPCResponse[json.RawMessage]
+
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	maxMessage int64
+
+	done chan struct{}
+	err  error
+}
+
+type WSOption func(*WSClient)
+
+// WithMaxMessageBytes bounds incoming messages, fragments included,
+// DefaultMaxResponseBytes unless set. A larger one closes the connection
+// with status 1009 and fails it with ErrResponseTooLarge. n <= 0 removes
+// the limit.
+func WithMaxMessageBytes(n int64) WSOption {
+	return func(c *WSClient) {
+		c.maxMessage = n
+	}
+}
+
+// DialWS opens a WebSocket connection to a ws:// or wss:// URL. header is
+// sent with the handshake and may be nil. ctx only bounds the handshake.
+func DialWS(ctx context.Context, rawURL string, header http.Header, opts ...WSOption) (*WSClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, eris.Wrap(err, "parse websocket url")
+	}
+
+	conn, err := dialWS(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := wsHandshake(ctx, conn, u, header)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c := &WSClient{
+		conn:          conn,
+		reader:        reader,
+		codec:         defaultCodec,
+		notifications: make(chan Notification, 64),
+		pending:       make(map[string]chan *RPCResponse[json.RawMessage]),
+		closing:       make(chan struct{}),
+		maxMessage:    DefaultMaxResponseBytes,
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	go c.read()
+
+	return c, nil
+}
+
+func dialWS(ctx context.Context, u *url.URL) (net.Conn, error) {
+	switch u.Scheme {
+	case "ws", "wss":
+		conn, err := dialHost(ctx, u, u.Scheme == "wss")
+		return conn, eris.Wrap(err, "dial websocket")
+	default:
+		return nil, eris.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+}
+
+// dialHost opens a raw connection to the host of u, over TLS when secure,
+// defaulting to port 80 or 443.
+func dialHost(ctx context.Context, u *url.URL, secure bool) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+
+	if !secure {
+		return dialer.DialContext(ctx, "tcp", host)
+	}
+
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12},
+	}
+
+	return tlsDialer.DialContext(ctx, "tcp", host)
+}
+
+func wsHandshake(ctx context.Context, conn net.Conn, u *url.URL, header http.Header) (*bufio.Reader, error) {
+	// a canceled ctx unblocks the handshake I/O by expiring the deadline
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+	}
+
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		return nil, eris.Wrap(err, "write websocket handshake")
+	}
+
+	reader := bufio.NewReaderSize(conn, 64<<10)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, eris.Wrap(err, "read websocket handshake")
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, &HTTPError{StatusCode: resp.StatusCode}
+	}
+
+	// SHA-1 is what RFC 6455 prescribes for the accept key
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, eris.New("websocket handshake: invalid Sec-WebSocket-Accept")
+	}
+
+	if !stop() {
+		return nil, eris.Wrap(ctx.Err(), "websocket handshake")
+	}
+
+	return reader, nil
+}
+
+func (c *WSClient) Notifications() <-chan Notification {
+	return c.notifications
+}
+
+// Call sends method with params and decodes the result into result, which
+// must be a pointer or nil.
+func (c *WSClient) Call(ctx context.Context, method string, params any, result any) error {
+	req := NewRequest[any, any](method, params)
+
+	raw, err := c.roundTrip(ctx, req.payload(), req.ID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return eris.Wrap(c.codec.Unmarshal(raw, result), "decode response")
+}
+
+// SendWS sends the request over c and waits for its response. A
+// notification, which has no id, returns as soon as it is written.
+func (r *rpcRequest[Params, Resp]) SendWS(ctx context.Context, c *WSClient) (*Resp, error) {
+	raw, err := c.roundTrip(ctx, r.payload(), r.ID)
+	if err != nil || r.ID == nil {
+		return nil, err
+	}
+
+	var result Resp
+	if err := c.codec.Unmarshal(raw, &result); err != nil {
+		return nil, eris.Wrap(err, "decode response")
+	}
+
+	return &result, nil
+}
+
+func (c *WSClient) roundTrip(ctx context.Context, payload any, id any) (json.RawMessage, error) {
+	data, err := c.codec.Marshal(payload)
+	if err != nil {
+		return nil, eris.Wrap(err, "encode request data")
+	}
+
+	if id == nil {
+		return nil, c.writeFrame(wsText, data)
+	}
+
+	key := idKey(id)
+	ch := make(chan *RPCResponse[json.RawMessage], 1)
+
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.writeFrame(wsText, data); err != nil {
+		// a failed write leaves the stream unusable; tear it down so the
+		// failure is visible through closed by the time err is returned
+		_ = c.conn.Close()
+		<-c.done
+
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, eris.Wrap(ctx.Err(), "await websocket response")
+	case <-c.done:
+		if c.err != nil {
+			return nil, eris.Wrap(c.err, "await websocket response")
+		}
+
+		return nil, eris.New("websocket closed before response arrived")
+	}
+}
+
+// closed reports whether the connection is gone, as opposed to a call on it
+// having failed on its own.
+func (c *WSClient) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err reports the error that terminated the connection, if any.
+func (c *WSClient) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close sends a close frame and tears the connection down.
+func (c *WSClient) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		_ = c.writeFrame(wsClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	})
+
+	err := c.conn.Close()
+	<-c.done
+
+	if eris.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// writeFrame sends a single masked frame, as RFC 6455 requires of clients.
+func (c *WSClient) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 0, 14)
+	header = append(header, 0x80|opcode)
+
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	mask := make([]byte, 4)
+	_, _ = rand.Read(mask)
+	header = append(header, mask...)
+
+	frame := make([]byte, len(header)+len(payload))
+	copy(frame, header)
+
+	for i, b := range payload {
+		frame[len(header)+i] = b ^ mask[i%4]
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := c.conn.Write(frame)
+
+	return eris.Wrap(err, "write websocket frame")
+}
+
+func (c *WSClient) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if c.maxMessage > 0 && length > uint64(c.maxMessage) {
+		return false, 0, nil, eris.Wrapf(ErrResponseTooLarge, "websocket frame of %d bytes", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	if c.maxMessage > 0 {
+		payload = make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return false, 0, nil, err
+		}
+	} else {
+		// without a limit the announced length is not trusted with an
+		// allocation up front; the payload grows as it arrives
+		payload, err = io.ReadAll(io.LimitReader(c.reader, int64(length)))
+		if err != nil {
+			return false, 0, nil, err
+		}
+
+		if uint64(len(payload)) != length {
+			return false, 0, nil, io.ErrUnexpectedEOF
+		}
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (c *WSClient) read() {
+	defer close(c.done)
+	defer close(c.notifications)
+
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			select {
+			case <-c.closing:
+			default:
+				c.err = eris.Wrap(err, "read websocket")
+			}
+			return
+		}
+
+		switch opcode {
+		case wsContinuation:
+			// fragments are bounded as a whole, like a single frame
+			if c.maxMessage > 0 && int64(len(message)+len(payload)) > c.maxMessage {
+				c.err = eris.Wrapf(ErrResponseTooLarge, "read websocket: message over %d bytes", c.maxMessage)
+				c.closeOnce.Do(func() {
+					close(c.closing)
+					_ = c.writeFrame(wsClose, []byte{0x03, 0xF1}) // 1009: message too big
+				})
+				_ = c.conn.Close()
+				return
+			}
+
+			message = append(message, payload...)
+		case wsText, wsBinary:
+			message = payload
+		case wsPing:
+			_ = c.writeFrame(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.closeOnce.Do(func() {
+				close(c.closing)
+				_ = c.writeFrame(wsClose, payload)
+			})
+			_ = c.conn.Close()
+			return
+		}
+
+		if fin {
+			c.dispatch(message)
+			message = nil
+		}
+	}
+}
+
+func (c *WSClient) dispatch(payload []byte) {
+	var msg sseEnvelope
+	if err := c.codec.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	if msg.ID == nil && msg.Method != "" {
+		select {
+		case c.notifications <- Notification{JSONRPC: msg.JSONRPC, Method: msg.Method, Params: msg.Params}:
+		case <-c.closing:
+		}
+		return
+	}
+
+	key := idKey(msg.ID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// responses nobody waits for any more, e.g. after a timeout, are dropped
+	if ch, ok := c.pending[key]; ok {
+		delete(c.pending, key)
+		ch <- &RPCResponse[json.RawMessage]{
+			JSONRPC: msg.JSONRPC,
+			Result:  msg.Result,
+			Error:   msg.Error,
+			ID:      msg.ID,
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// wsPeer is the server end of a test WebSocket connection.
+type wsPeer struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+func (p *wsPeer) readText() ([]byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(p.reader, head[:]); err != nil {
+		return nil, err
+	}
+
+	if head[0]&0x0F == 0x8 {
+		return nil, io.EOF
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(p.reader, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(p.reader, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(p.reader, mask[:]); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
+		return nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return payload, nil
+}
+
+// writeText sends an unmasked text message, split into two fragments to
+// exercise continuation frames.
+func (p *wsPeer) writeText(payload string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	half := len(payload) / 2
+	p.writeFrame(0x1, payload[:half])
+	p.writeFrame(0x80, payload[half:])
+}
+
+func (p *wsPeer) writeFrame(first byte, payload string) {
+	frame := []byte{first}
+	if len(payload) < 126 {
+		frame = append(frame, byte(len(payload)))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+
+	_, _ = p.conn.Write(append(frame, payload...))
+}
+
+func newWSServer(t *testing.T, serve func(p *wsPeer)) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "websocket", r.Header.Get("Upgrade"))
+
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(sum[:]))
+		require.NoError(t, rw.Flush())
+
+		serve(&wsPeer{conn: conn, reader: rw.Reader})
+	}))
+}
+
+type wsRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     json.RawMessage `json:"id"`
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWSClientConcurrentCalls(t *testing.T) {
+	t.Parallel()
+
+	server := newWSServer(t, func(p *wsPeer) {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		for {
+			payload, err := p.readText()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			// answer slower calls later so responses arrive out of order
+			var params []int
+			require.NoError(t, json.Unmarshal(req.Params, &params))
+
+			wg.Go(func() {
+				time.Sleep(time.Duration(params[0]) * time.Millisecond)
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":%d,"id":%s}`, params[0], req.ID))
+			})
+		}
+	})
+	defer server.Close()
+
+	client, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for _, delay := range []int{60, 30, 1} {
+		wg.Go(func() {
+			res, err := jsonrpc.NewRequest[[]int, int]("sleep", []int{delay}).SendWS(context.Background(), client)
+			require.NoError(t, err)
+			require.Equal(t, delay, *res)
+		})
+	}
+	wg.Wait()
+
+	var res int
+	require.NoError(t, client.Call(context.Background(), "sleep", []int{1}, &res))
+	require.Equal(t, 1, res)
+}
+
+func TestWSClientSubscription(t *testing.T) {
+	t.Parallel()
+
+	server := newWSServer(t, func(p *wsPeer) {
+		for {
+			payload, err := p.readText()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			switch req.Method {
+			case "eth_subscribe":
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":"0xabc","id":%s}`, req.ID))
+				for n := range 2 {
+					p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xabc","result":{"number":"0x%d"}}}`, n))
+				}
+			default:
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":%s}`, req.ID))
+			}
+		}
+	})
+	defer server.Close()
+
+	client, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil)
+	require.NoError(t, err)
+
+	var subscription string
+	require.NoError(t, client.Call(context.Background(), "eth_subscribe", []string{"newHeads"}, &subscription))
+	require.Equal(t, "0xabc", subscription)
+
+	for n := range 2 {
+		notification := <-client.Notifications()
+		require.Equal(t, "eth_subscription", notification.Method)
+		require.JSONEq(t, fmt.Sprintf(`{"subscription":"0xabc","result":{"number":"0x%d"}}`, n), string(notification.Params))
+	}
+
+	err = client.Call(context.Background(), "eth_unknown", nil, nil)
+	require.ErrorIs(t, err, jsonrpc.ErrMethodNotFound)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Err())
+
+	_, open := <-client.Notifications()
+	require.False(t, open)
+}
+
+func TestWSClientConnectionDrop(t *testing.T) {
+	t.Parallel()
+
+	server := newWSServer(t, func(p *wsPeer) {
+		_, _ = p.readText()
+	})
+	defer server.Close()
+
+	client, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.Call(context.Background(), "getblock", nil, nil)
+	require.Error(t, err)
+	require.Error(t, client.Err())
+}
+
+func TestWSClientBoundsFragmentedMessages(t *testing.T) {
+	t.Parallel()
+
+	closeCode := make(chan uint16, 1)
+
+	server := newWSServer(t, func(p *wsPeer) {
+		// eleven 100-byte fragments, the last one past the 1KB bound
+		fragment := strings.Repeat("x", 100)
+		p.writeFrame(0x1, fragment)
+		for range 10 {
+			p.writeFrame(0x0, fragment)
+		}
+
+		var frame [8]byte
+		if _, err := io.ReadFull(p.reader, frame[:]); err != nil || frame[0]&0x0F != 0x8 {
+			close(closeCode)
+			return
+		}
+
+		closeCode <- binary.BigEndian.Uint16(frame[6:]) ^ binary.BigEndian.Uint16(frame[2:4])
+	})
+	defer server.Close()
+
+	client, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil, jsonrpc.WithMaxMessageBytes(1024))
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.Equal(t, uint16(1009), <-closeCode)
+
+	require.Eventually(t, func() bool {
+		return errors.Is(client.Err(), jsonrpc.ErrResponseTooLarge)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestWSClientMaxMessageBytesUnlimited(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Repeat("x", 2000)
+
+	server := newWSServer(t, func(p *wsPeer) {
+		for {
+			payload, err := p.readText()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			// fragmented, so both the frame and the message bound are passed
+			p.writeFrame(0x1, `{"jsonrpc":"2.0","result":"`)
+			p.writeFrame(0x0, data[:1000])
+			p.writeFrame(0x0, data[1000:])
+			p.writeFrame(0x80, fmt.Sprintf(`","id":%s}`, req.ID))
+		}
+	})
+	defer server.Close()
+
+	for _, n := range []int64{0, -1} {
+		client, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil, jsonrpc.WithMaxMessageBytes(n))
+		require.NoError(t, err)
+
+		var result string
+		require.NoError(t, client.Call(context.Background(), "getblock", nil, &result))
+		require.Equal(t, data, result)
+		require.NoError(t, client.Close())
+	}
+}
+
+func TestDialWSRejectsNonUpgrade(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := jsonrpc.DialWS(context.Background(), wsURL(server), nil)
+
+	var httpErr *jsonrpc.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
@@ -0,0 +1,461 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// SubscriptionEvent is delivered on a subscription's channel. Result holds a
+// notification's "result" member. Gap marks a point where notifications may
+// have been lost, after a reconnect or when the consumer fell behind; a
+// header follower should refetch from its last known block. Err is set on
+// the last event when the subscription ends because the server rejected
+// resubscribing.
+type SubscriptionEvent struct {
+	Result json.RawMessage
+	Gap    bool
+	Err    error
+}
+
+// subscriptionQueue bounds the events held for a slow consumer.
+const subscriptionQueue = 1024
+
+// unroutedLimit bounds the notifications held for subscription ids not
+// known yet, i.e. arriving before the subscribe response is processed.
+const unroutedLimit = 256
+
+// WSSubscriber keeps subscriptions, e.g. eth_subscribe("newHeads"), alive
+// over a WebSocket connection that it redials with backoff whenever it
+// drops, subscribing again and resuming delivery. It is safe for
+// concurrent use.
+type WSSubscriber struct {
+	url     string
+	header  http.Header
+	backoff BackoffFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	conn     *WSClient
+	changed  chan struct{}              // closed when conn is replaced
+	subs     map[*subscription]struct{} // wanted, renewed on reconnect
+	byID     map[string]*subscription   // live on conn
+	unrouted map[string][]json.RawMessage
+}
+
+type SubscriberOption func(*WSSubscriber)
+
+// WithReconnectBackoff sets the wait before the attempt-th redial; by
+// default ExponentialBackoff(100ms, 5s).
+func WithReconnectBackoff(backoff BackoffFunc) SubscriberOption {
+	return func(s *WSSubscriber) {
+		s.backoff = backoff
+	}
+}
+
+type subscription struct {
+	method string
+	params any
+	id     any
+	key    string
+
+	events chan SubscriptionEvent
+	wake   chan struct{}
+	done   chan struct{}
+	end    sync.Once
+
+	// queue is guarded by the subscriber's mu
+	queue []SubscriptionEvent
+}
+
+// DialWSSubscriber connects to a ws:// or wss:// URL like DialWS. ctx only
+// bounds the first handshake; redials last until Close.
+func DialWSSubscriber(ctx context.Context, url string, header http.Header, opts ...SubscriberOption) (*WSSubscriber, error) {
+	conn, err := DialWS(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &WSSubscriber{
+		url:      url,
+		header:   header.Clone(),
+		backoff:  ExponentialBackoff(100*time.Millisecond, 5*time.Second),
+		done:     make(chan struct{}),
+		conn:     conn,
+		changed:  make(chan struct{}),
+		subs:     make(map[*subscription]struct{}),
+		byID:     make(map[string]*subscription),
+		unrouted: make(map[string][]json.RawMessage),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	go s.run(conn)
+
+	return s, nil
+}
+
+// Subscribe calls method, e.g. eth_subscribe, with params and delivers the
+// notifications of the subscription it returns on the channel, which must
+// be drained. The subscription is renewed on every reconnect, each time
+// followed by a Gap event. unsubscribe ends it and closes the channel; when
+// method ends in "subscribe" or "Subscribe" the server is told too, calling
+// the matching "unsubscribe" method.
+func (s *WSSubscriber) Subscribe(ctx context.Context, method string, params any) (<-chan SubscriptionEvent, func(), error) {
+	sub := &subscription{
+		method: method,
+		params: params,
+		events: make(chan SubscriptionEvent),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	for {
+		s.mu.Lock()
+		conn, changed := s.conn, s.changed
+		s.mu.Unlock()
+
+		if conn == nil {
+			return nil, nil, eris.New("subscribe: subscriber closed")
+		}
+
+		id, err := subscribeOn(ctx, conn, method, params)
+		if err == nil && s.register(conn, sub, id, false) {
+			break
+		}
+
+		// only a dropped connection is worth another try; any other failure,
+		// e.g. an RPC error or a missing subscription id, would repeat
+		if err != nil && !conn.closed() {
+			return nil, nil, eris.Wrap(err, "subscribe")
+		}
+
+		// the connection dropped meanwhile; try again on the next one
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, nil, eris.Wrap(ctx.Err(), "subscribe")
+		}
+	}
+
+	go s.forward(sub)
+
+	return sub.events, func() { s.unsubscribe(sub) }, nil
+}
+
+// Close ends every subscription, closing their channels, and the connection.
+func (s *WSSubscriber) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+
+	<-s.done
+
+	return err
+}
+
+func subscribeOn(ctx context.Context, conn *WSClient, method string, params any) (any, error) {
+	var id any
+	if err := conn.Call(ctx, method, params, &id); err != nil {
+		return nil, err
+	}
+
+	if id == nil {
+		return nil, eris.Errorf("%s returned no subscription id", method)
+	}
+
+	return id, nil
+}
+
+// register routes the notifications of id to sub unless conn was replaced
+// meanwhile, in which case the subscription died with it. Notifications
+// that arrived before the id was known are delivered first.
+func (s *WSSubscriber) register(conn *WSClient, sub *subscription, id any, gap bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != conn {
+		return false
+	}
+
+	if gap {
+		if _, wanted := s.subs[sub]; !wanted {
+			return true
+		}
+	}
+
+	key := idKey(id)
+	sub.id, sub.key = id, key
+	s.subs[sub] = struct{}{}
+	s.byID[key] = sub
+
+	if gap {
+		sub.push(SubscriptionEvent{Gap: true})
+	}
+
+	for _, result := range s.unrouted[key] {
+		sub.push(SubscriptionEvent{Result: result})
+	}
+
+	delete(s.unrouted, key)
+
+	return true
+}
+
+func (s *WSSubscriber) unsubscribe(sub *subscription) {
+	s.mu.Lock()
+	delete(s.subs, sub)
+
+	live := s.byID[sub.key] == sub
+	if live {
+		delete(s.byID, sub.key)
+	}
+
+	conn, id := s.conn, sub.id
+	s.mu.Unlock()
+
+	sub.stop()
+
+	method, ok := unsubscribeMethod(sub.method)
+	if !live || !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	_ = conn.Call(ctx, method, []any{id}, nil)
+}
+
+func unsubscribeMethod(method string) (string, bool) {
+	if prefix, ok := strings.CutSuffix(method, "Subscribe"); ok {
+		return prefix + "Unsubscribe", true
+	}
+
+	if prefix, ok := strings.CutSuffix(method, "subscribe"); ok {
+		return prefix + "unsubscribe", true
+	}
+
+	return "", false
+}
+
+// run routes the notifications of each connection in turn, redialing and
+// subscribing again whenever one drops.
+func (s *WSSubscriber) run(conn *WSClient) {
+	defer close(s.done)
+
+	for conn != nil {
+		s.route(conn)
+		conn = s.reconnect()
+	}
+
+	s.mu.Lock()
+	subs := s.subs
+	s.subs = nil
+	s.conn = nil
+	close(s.changed)
+	s.mu.Unlock()
+
+	for sub := range subs {
+		sub.stop()
+	}
+}
+
+type subscriptionParams struct {
+	Subscription any             `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+// route hands notifications to their subscription until conn drops. It
+// never blocks on a consumer, so responses on conn keep flowing.
+func (s *WSSubscriber) route(conn *WSClient) {
+	for n := range conn.Notifications() {
+		var params subscriptionParams
+		if err := conn.codec.Unmarshal(n.Params, &params); err != nil || params.Subscription == nil {
+			continue
+		}
+
+		id := idKey(params.Subscription)
+
+		s.mu.Lock()
+		if sub, ok := s.byID[id]; ok {
+			sub.push(SubscriptionEvent{Result: params.Result})
+		} else if held := s.unrouted[id]; len(held) < unroutedLimit {
+			s.unrouted[id] = append(held, params.Result)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// reconnect redials until it succeeds or the subscriber is closed, in which
+// case it returns nil. The subscriptions are renewed in the background, as
+// their responses arrive through route; those not renewed before the new
+// connection drops too are renewed on the one after.
+func (s *WSSubscriber) reconnect() *WSClient {
+	s.mu.Lock()
+	old := s.conn
+	s.mu.Unlock()
+
+	_ = old.Close()
+
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(s.backoff(attempt))
+
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		conn, err := DialWS(s.ctx, s.url, s.header)
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.ctx.Err() != nil {
+			s.mu.Unlock()
+			_ = conn.Close()
+
+			return nil
+		}
+
+		s.conn = conn
+		close(s.changed)
+		s.changed = make(chan struct{})
+
+		subs := make([]*subscription, 0, len(s.subs))
+		for sub := range s.subs {
+			subs = append(subs, sub)
+		}
+
+		clear(s.byID)
+		clear(s.unrouted)
+		s.mu.Unlock()
+
+		go s.resubscribe(conn, subs)
+
+		return conn
+	}
+}
+
+func (s *WSSubscriber) resubscribe(conn *WSClient, subs []*subscription) {
+	for _, sub := range subs {
+		s.mu.Lock()
+		_, wanted := s.subs[sub]
+		s.mu.Unlock()
+
+		if !wanted {
+			continue
+		}
+
+		id, err := subscribeOn(s.ctx, conn, sub.method, sub.params)
+		if err != nil {
+			// the new connection failed too, or the subscriber is closing;
+			// either way this connection is done with
+			if conn.closed() || s.ctx.Err() != nil {
+				return
+			}
+
+			s.fail(sub, eris.Wrap(err, "resubscribe"))
+
+			continue
+		}
+
+		if !s.register(conn, sub, id, true) {
+			return
+		}
+	}
+}
+
+// fail ends sub with a last event carrying err.
+func (s *WSSubscriber) fail(sub *subscription, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subs, sub)
+	sub.queue = append(sub.queue, SubscriptionEvent{Err: err})
+
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push queues ev for the consumer; s.mu must be held. A consumer that
+// falls too far behind loses notifications, marked by a Gap event.
+func (sub *subscription) push(ev SubscriptionEvent) {
+	if n := len(sub.queue); n >= subscriptionQueue {
+		if !sub.queue[n-1].Gap {
+			sub.queue = append(sub.queue, SubscriptionEvent{Gap: true})
+		}
+
+		return
+	}
+
+	sub.queue = append(sub.queue, ev)
+
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// forward moves queued events to the consumer in order.
+func (s *WSSubscriber) forward(sub *subscription) {
+	defer close(sub.events)
+
+	for {
+		s.mu.Lock()
+		if len(sub.queue) == 0 {
+			s.mu.Unlock()
+
+			select {
+			case <-sub.wake:
+				continue
+			case <-sub.done:
+				return
+			}
+		}
+
+		ev := sub.queue[0]
+		sub.queue = sub.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case sub.events <- ev:
+		case <-sub.done:
+			return
+		}
+
+		if ev.Err != nil {
+			return
+		}
+	}
+}
+
+func (sub *subscription) stop() {
+	sub.end.Do(func() {
+		close(sub.done)
+	})
+}
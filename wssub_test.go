@@ -0,0 +1,253 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/LiquidCats/jsonrpc/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, events <-chan jsonrpc.SubscriptionEvent) jsonrpc.SubscriptionEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		require.True(t, ok, "subscription channel closed")
+		return ev
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no subscription event")
+		return jsonrpc.SubscriptionEvent{}
+	}
+}
+
+func TestWSSubscriberEarlyNotificationAndUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	unsubscribed := make(chan string, 1)
+
+	server := newWSServer(t, func(p *wsPeer) {
+		for {
+			payload, err := p.readText()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			switch req.Method {
+			case "eth_subscribe":
+				// the first notification overtakes the subscribe response
+				p.writeText(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xabc","result":1}}`)
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":"0xabc","id":%s}`, req.ID))
+				p.writeText(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xabc","result":2}}`)
+			case "eth_unsubscribe":
+				unsubscribed <- string(req.Params)
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":true,"id":%s}`, req.ID))
+			}
+		}
+	})
+	defer server.Close()
+
+	sub, err := jsonrpc.DialWSSubscriber(context.Background(), wsURL(server), nil)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	events, unsubscribe, err := sub.Subscribe(context.Background(), "eth_subscribe", []string{"newHeads"})
+	require.NoError(t, err)
+
+	require.JSONEq(t, `1`, string(nextEvent(t, events).Result))
+	require.JSONEq(t, `2`, string(nextEvent(t, events).Result))
+
+	unsubscribe()
+	require.JSONEq(t, `["0xabc"]`, <-unsubscribed)
+
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestWSSubscriberResubscribesAfterDrop(t *testing.T) {
+	t.Parallel()
+
+	var conns atomic.Int32
+
+	server := newWSServer(t, func(p *wsPeer) {
+		n := conns.Add(1)
+
+		payload, err := p.readText()
+		if err != nil {
+			return
+		}
+
+		var req wsRequest
+		require.NoError(t, json.Unmarshal(payload, &req))
+		require.Equal(t, "eth_subscribe", req.Method)
+		require.JSONEq(t, `["newHeads"]`, string(req.Params))
+
+		p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":"0x%d","id":%s}`, n, req.ID))
+		p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x%d","result":%d}}`, n, n))
+
+		if n == 1 {
+			return // drop the connection
+		}
+
+		for {
+			if _, err := p.readText(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	sub, err := jsonrpc.DialWSSubscriber(context.Background(), wsURL(server), nil,
+		jsonrpc.WithReconnectBackoff(jsonrpc.ExponentialBackoff(time.Millisecond, 10*time.Millisecond)))
+	require.NoError(t, err)
+
+	events, _, err := sub.Subscribe(context.Background(), "eth_subscribe", []string{"newHeads"})
+	require.NoError(t, err)
+
+	require.JSONEq(t, `1`, string(nextEvent(t, events).Result))
+	require.True(t, nextEvent(t, events).Gap)
+	require.JSONEq(t, `2`, string(nextEvent(t, events).Result))
+	require.EqualValues(t, 2, conns.Load())
+
+	require.NoError(t, sub.Close())
+
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestWSSubscriberRejectedResubscribe(t *testing.T) {
+	t.Parallel()
+
+	var conns atomic.Int32
+
+	server := newWSServer(t, func(p *wsPeer) {
+		n := conns.Add(1)
+
+		payload, err := p.readText()
+		if err != nil {
+			return
+		}
+
+		var req wsRequest
+		require.NoError(t, json.Unmarshal(payload, &req))
+
+		if n == 1 {
+			p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":"0x1","id":%s}`, req.ID))
+			return
+		}
+
+		p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":%s}`, req.ID))
+
+		for {
+			if _, err := p.readText(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	sub, err := jsonrpc.DialWSSubscriber(context.Background(), wsURL(server), nil,
+		jsonrpc.WithReconnectBackoff(jsonrpc.ExponentialBackoff(time.Millisecond, 10*time.Millisecond)))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	events, _, err := sub.Subscribe(context.Background(), "eth_subscribe", []string{"newHeads"})
+	require.NoError(t, err)
+
+	ev := nextEvent(t, events)
+	require.ErrorIs(t, ev.Err, jsonrpc.ErrMethodNotFound)
+
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+// newNullIDServer answers every call with a null result, which is no
+// subscription id, except for a first connection with failFirst: that one
+// answers with an id, then drops.
+func newNullIDServer(t *testing.T, conns *atomic.Int32, failFirst bool) *httptest.Server {
+	t.Helper()
+
+	return newWSServer(t, func(p *wsPeer) {
+		n := conns.Add(1)
+
+		for {
+			payload, err := p.readText()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			if failFirst && n == 1 {
+				p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":"0x1","id":%s}`, req.ID))
+				return
+			}
+
+			p.writeText(fmt.Sprintf(`{"jsonrpc":"2.0","result":null,"id":%s}`, req.ID))
+		}
+	})
+}
+
+func TestWSSubscriberSubscribeFailsWithoutID(t *testing.T) {
+	t.Parallel()
+
+	var conns atomic.Int32
+
+	server := newNullIDServer(t, &conns, false)
+	defer server.Close()
+
+	sub, err := jsonrpc.DialWSSubscriber(context.Background(), wsURL(server), nil)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := sub.Subscribe(context.Background(), "eth_subscribe", []string{"newHeads"})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, "returned no subscription id")
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "subscribe blocked on a healthy connection")
+	}
+
+	require.EqualValues(t, 1, conns.Load())
+}
+
+func TestWSSubscriberResubscribeWithoutIDFailsSubscription(t *testing.T) {
+	t.Parallel()
+
+	var conns atomic.Int32
+
+	server := newNullIDServer(t, &conns, true)
+	defer server.Close()
+
+	sub, err := jsonrpc.DialWSSubscriber(context.Background(), wsURL(server), nil,
+		jsonrpc.WithReconnectBackoff(jsonrpc.ExponentialBackoff(time.Millisecond, 10*time.Millisecond)))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	events, _, err := sub.Subscribe(context.Background(), "eth_subscribe", []string{"newHeads"})
+	require.NoError(t, err)
+
+	ev := nextEvent(t, events)
+	require.ErrorContains(t, ev.Err, "returned no subscription id")
+
+	_, ok := <-events
+	require.False(t, ok)
+
+	// the working connection is kept rather than redialed over and over
+	time.Sleep(50 * time.Millisecond)
+	require.EqualValues(t, 2, conns.Load())
+}